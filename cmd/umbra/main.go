@@ -3,20 +3,35 @@ package main
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/henomis/umbra/config"
+	"github.com/henomis/umbra/internal/compress"
 	"github.com/henomis/umbra/internal/ghost"
 	"github.com/henomis/umbra/internal/provider"
+	"github.com/henomis/umbra/internal/provider/paste"
+	"github.com/henomis/umbra/internal/provider/plugin"
+	"github.com/henomis/umbra/internal/selection"
 	"github.com/henomis/umbra/umbra"
 )
 
 const version = "0.0.1"
 
+// Exit codes of the verify command, failures exit with 1.
+const (
+	exitDegraded      = 2
+	exitUnrecoverable = 3
+)
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -24,9 +39,19 @@ func main() {
 	}
 }
 
+// exit closes the providers of u, stopping the plugins it started, and exits
+// with code, as deferred calls do not run on os.Exit.
+func exit(u *umbra.Umbra, code int) {
+	_ = u.Close()
+	os.Exit(code)
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "umbra",
 	Short: "Umbra securely split, encrypt, and redundantly store files across pluggable providers via CLI.",
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		return loadProviderSettings()
+	},
 }
 
 var versionCmd = &cobra.Command{
@@ -43,13 +68,74 @@ var providersCmd = &cobra.Command{
 	Aliases: []string{"p"},
 	Short:   "List available storage providers",
 	Run: func(_ *cobra.Command, _ []string) {
-		fmt.Println("Available providers:")
-		for _, p := range provider.DefaultProviders {
-			fmt.Printf("  - %s\n", p)
+		plugin.Discover()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(w, "Available providers:")
+		for _, r := range provider.Registrations() {
+			fmt.Fprintf(w, "  - %s\t%s\t%s\n", r.Name, providerStatus(r), describeCapabilities(r.Capabilities))
+			if len(r.Options) > 0 {
+				fmt.Fprintf(w, "    \t\toptions: %s\n", strings.Join(r.Options, ", "))
+			}
 		}
+
+		if len(pasteSites) > 0 {
+			fmt.Fprintln(w, "Paste sites:")
+			for _, name := range slices.Sorted(maps.Keys(pasteSites)) {
+				spec := pasteSites[name]
+				status := fmt.Sprintf("%s paste site %s", spec.Transport, spec.Endpoint)
+				if slices.Contains(disabledProviders, name) {
+					status += " (disabled)"
+				}
+				fmt.Fprintf(w, "  - %s\t%s\t%s\n", name, status, describeCapabilities(spec.Capabilities()))
+				fmt.Fprintf(w, "    \t\toptions: %s\n", strings.Join(spec.Options(), ", "))
+			}
+		}
+
+		if len(providerAliases) > 0 {
+			fmt.Fprintln(w, "Aliases:")
+			for _, alias := range slices.Sorted(maps.Keys(providerAliases)) {
+				fmt.Fprintf(w, "  - %s\talias of %s\n", alias, providerAliases[alias])
+			}
+		}
+
+		w.Flush()
 	},
 }
 
+// describeCapabilities summarizes the limits of a provider.
+func describeCapabilities(c provider.Capabilities) string {
+	// plugins report their limits once started
+	if c.MaxSize == 0 {
+		return "limits reported at runtime"
+	}
+
+	expire := "no expiry"
+	if c.Expire > 0 {
+		expire = "expires after " + c.Expire.String()
+	}
+
+	encoding := "raw"
+	if c.Encoding != "" {
+		encoding = c.Encoding
+	}
+
+	return fmt.Sprintf("max %d bytes, %s, %s", c.MaxSize, encoding, expire)
+}
+
+// providerStatus describes a provider and whether it is used by default.
+func providerStatus(r provider.Registration) string {
+	switch {
+	case slices.Contains(disabledProviders, r.Name):
+		return r.Description + " (disabled)"
+	case !r.Default:
+		return r.Description + " (not used by default)"
+	default:
+		return r.Description
+	}
+}
+
 /*
  * =====================
  * Upload Command
@@ -57,18 +143,49 @@ var providersCmd = &cobra.Command{
  */
 
 var (
-	uploadFile string
-	password   string
-	chunkSize  int64
-	chunks     int
-	copies     int
-	providers  []string
-	// rawOptions   []string // for future use.
-	// options      map[string]string // for future use.
+	uploadFile   string
+	password     string
+	chunkSize    int64
+	chunks       int
+	rawChunks    string
+	copies       int
+	providers    []string
 	outputFile   string
 	manifestPath string
 	quiet        bool
 	ghostMode    string
+	compression  string
+	offset       int64
+	length       int64
+	parallel     int
+	provParallel map[string]int
+	hedge        time.Duration
+	retries      int
+	retryDelay   time.Duration
+	journalPath  string
+	resume       bool
+	abandon      bool
+	force        bool
+	dryRun       bool
+	jsonOutput   bool
+	sample       int
+	repairCopies int
+	before       time.Duration
+	manifests    []string
+	evacuated    []string
+
+	configFile        string
+	rawOptions        []string
+	providerOptions   map[string]map[string]string
+	disabledProviders []string
+	providerAliases   map[string]string
+	pasteSites        map[string]paste.Spec
+
+	selectionPolicy string
+	providerWeights map[string]int
+	rawProviderTags map[string]string
+	providerTags    map[string]map[string]string
+	distinctTags    []string
 )
 
 var infoCmd = &cobra.Command{
@@ -77,9 +194,13 @@ var infoCmd = &cobra.Command{
 	Short:   "Display manifest information",
 	Run: func(_ *cobra.Command, _ []string) {
 		cfg := &config.Config{
-			ManifestPath: manifestPath,
-			Password:     password,
-			GhostMode:    ghostMode,
+			ManifestPath:      manifestPath,
+			Password:          password,
+			GhostMode:         ghostMode,
+			DisabledProviders: disabledProviders,
+			ProviderAliases:   providerAliases,
+			PasteSites:        pasteSites,
+			ProviderOptions:   providerOptions,
 		}
 
 		umbraInstance, err := umbra.New(cfg)
@@ -87,10 +208,11 @@ var infoCmd = &cobra.Command{
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		defer umbraInstance.Close()
 
 		if err := umbraInstance.Info(context.Background()); err != nil {
 			fmt.Println(err)
-			os.Exit(1)
+			exit(umbraInstance, 1)
 		}
 	},
 }
@@ -99,35 +221,71 @@ var uploadCmd = &cobra.Command{
 	Use:     "upload",
 	Aliases: []string{"u"},
 	Short:   "Upload a file",
-	PreRunE: func(_ *cobra.Command, _ []string) error {
-		// For future use
-		// parsed, err := parseKeyValueOptions(rawOptions)
-		// if err != nil {
-		// 	return err
-		// }
+	PreRunE: func(cmd *cobra.Command, _ []string) error {
+		// Parse number of chunks
+		if rawChunks == "auto" {
+			chunks = config.AutoChunks
+		} else if n, err := strconv.Atoi(rawChunks); err == nil && n > 0 {
+			chunks = n
+		} else {
+			return fmt.Errorf("invalid number of chunks %q: must be a positive integer or auto", rawChunks)
+		}
+
+		// An explicit chunk size replaces the default number of chunks
+		if cmd.Flags().Changed("chunk-size") {
+			chunks = 0
+		}
 
-		// options = parsed
+		if err := validatePlacementFlags(); err != nil {
+			return err
+		}
+
+		if jsonOutput && !dryRun {
+			return fmt.Errorf("--json requires --dry-run")
+		}
 
 		// Validate ghost mode
 		if ghostMode != "" && !ghost.IsValidGhostMode(ghostMode) {
 			return fmt.Errorf("invalid ghost mode %q: must be one of %s", ghostMode, strings.Join(ghost.Modes(), ", "))
 		}
 
+		// Validate compression mode
+		if compression != "" && !compress.IsValidMode(compression) {
+			return fmt.Errorf("invalid compression mode %q: must be one of %s", compression, strings.Join(compress.Modes(), ", "))
+		}
+
 		return nil
 	},
 	Run: func(_ *cobra.Command, _ []string) {
 		cfg := &config.Config{
-			ManifestPath: manifestPath,
-			Password:     password,
-			Quiet:        quiet,
-			Providers:    providers,
-			// Options:      options, // for future use
-			GhostMode: ghostMode,
+			ManifestPath:      manifestPath,
+			Password:          password,
+			Quiet:             quiet,
+			Providers:         providers,
+			GhostMode:         ghostMode,
+			DisabledProviders: disabledProviders,
+			ProviderAliases:   providerAliases,
+			PasteSites:        pasteSites,
+			ProviderOptions:   providerOptions,
+			Selection:         selectionPolicy,
+			ProviderWeights:   providerWeights,
+			ProviderTags:      providerTags,
+			DistinctTags:      distinctTags,
 			Upload: &config.Upload{
-				InputFilePath: uploadFile,
-				ChunkSize:     chunkSize,
-				Chunks:        chunks,
-				Copies:        copies,
+				InputFilePath:    uploadFile,
+				ChunkSize:        chunkSize,
+				Chunks:           chunks,
+				Copies:           copies,
+				Compress:         compression,
+				Parallel:         parallel,
+				Retries:          retries,
+				RetryDelay:       retryDelay,
+				JournalPath:      journalPath,
+				Resume:           resume,
+				Abandon:          abandon,
+				DryRun:           dryRun,
+				JSON:             jsonOutput,
+				ProviderParallel: provParallel,
 			},
 		}
 
@@ -136,28 +294,107 @@ var uploadCmd = &cobra.Command{
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		defer umbraInstance.Close()
 
 		if err := umbraInstance.Upload(context.Background()); err != nil {
 			fmt.Println(err)
-			os.Exit(1)
+			exit(umbraInstance, 1)
 		}
 	},
 }
 
-// For future use
-// func parseKeyValueOptions(input []string) (map[string]string, error) {
-// 	result := make(map[string]string)
+// parseProviderOptions parses options in provider.key=value form into the
+// options of each provider.
+func parseProviderOptions(input []string) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string)
+
+	for _, item := range input {
+		key, value, ok := strings.Cut(item, "=")
+		name, option, hasName := strings.Cut(key, ".")
+		if !ok || !hasName || name == "" || option == "" {
+			return nil, fmt.Errorf("invalid option %q, expected provider.key=value", item)
+		}
+
+		if result[name] == nil {
+			result[name] = make(map[string]string)
+		}
+		result[name][option] = value
+	}
+
+	return result, nil
+}
+
+// loadProviderSettings parses the provider options and adds the settings of
+// the configuration file to the provider flags, which take precedence.
+func loadProviderSettings() error {
+	options, err := parseProviderOptions(rawOptions)
+	if err != nil {
+		return err
+	}
+
+	// the default configuration file is optional
+	path, optional := configFile, false
+	if path == "" {
+		if path, err = config.DefaultFilePath(); err != nil {
+			path = ""
+		}
+		optional = true
+	}
+
+	settings := &config.Config{
+		ProviderOptions:   options,
+		ProviderAliases:   providerAliases,
+		PasteSites:        pasteSites,
+		DisabledProviders: disabledProviders,
+	}
+
+	if path != "" {
+		file, err := config.LoadFile(path, optional)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration file: %w", err)
+		}
+		file.Apply(settings)
+	}
+
+	providerOptions = settings.ProviderOptions
+	providerAliases = settings.ProviderAliases
+	disabledProviders = settings.DisabledProviders
+	pasteSites = settings.PasteSites
+
+	return nil
+}
+
+// addPlacementFlags registers the flags choosing the providers of new chunk
+// copies.
+func addPlacementFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&selectionPolicy, "selection", selection.Random, fmt.Sprintf("specify policy selecting the provider of each chunk copy. (%s)", strings.Join(selection.Policies(), ", ")))
+	cmd.Flags().StringToIntVar(&providerWeights, "provider-weight", map[string]int{}, "specify provider weights for the weighted policy in provider=N form (e.g. termbin=3)")
+	cmd.Flags().StringToStringVar(&rawProviderTags, "provider-tag", map[string]string{}, "tag providers in provider.key=value form (e.g. termbin.jurisdiction=us)")
+	cmd.Flags().StringSliceVar(&distinctTags, "distinct-tag", []string{}, "specify tag keys whose value must differ between the copies of a chunk (e.g. jurisdiction)")
+}
 
-// 	for _, item := range input {
-// 		parts := strings.SplitN(item, "=", 2)
-// 		if len(parts) != 2 || parts[0] == "" {
-// 			return nil, fmt.Errorf("invalid option %q, expected key=value", item)
-// 		}
-// 		result[parts[0]] = parts[1]
-// 	}
+// validatePlacementFlags checks the selection policy and parses the provider
+// tags.
+func validatePlacementFlags() error {
+	if !selection.IsValidPolicy(selectionPolicy) {
+		return fmt.Errorf("invalid selection policy %q: must be one of %s", selectionPolicy, strings.Join(selection.Policies(), ", "))
+	}
+
+	providerTags = make(map[string]map[string]string)
+	for key, value := range rawProviderTags {
+		name, tag, ok := strings.Cut(key, ".")
+		if !ok || name == "" || tag == "" {
+			return fmt.Errorf("invalid provider tag %q, expected provider.key=value", key)
+		}
 
-// 	return result, nil
-// }
+		if providerTags[name] == nil {
+			providerTags[name] = make(map[string]string)
+		}
+		providerTags[name][tag] = value
+	}
+
+	return nil
+}
 
 /*
  * =====================
@@ -170,13 +407,6 @@ var downloadCmd = &cobra.Command{
 	Aliases: []string{"d"},
 	Short:   "Download a file using a manifest",
 	PreRunE: func(_ *cobra.Command, _ []string) error {
-		// For future use
-		// parsed, err := parseKeyValueOptions(rawOptions)
-		// if err != nil {
-		// 	return err
-		// }
-		// options = parsed
-
 		// Validate ghost mode
 		if ghostMode != "" && !ghost.IsValidGhostMode(ghostMode) {
 			return fmt.Errorf("invalid ghost mode %q: must be one of %s", ghostMode, strings.Join(ghost.Modes(), ", "))
@@ -186,26 +416,282 @@ var downloadCmd = &cobra.Command{
 	},
 	Run: func(_ *cobra.Command, _ []string) {
 		cfg := &config.Config{
-			ManifestPath: manifestPath,
-			Password:     password,
-			Quiet:        quiet,
-			// Options:      options, // for future use
-			GhostMode: ghostMode,
+			ManifestPath:      manifestPath,
+			Password:          password,
+			Quiet:             quiet,
+			GhostMode:         ghostMode,
+			DisabledProviders: disabledProviders,
+			ProviderAliases:   providerAliases,
+			PasteSites:        pasteSites,
+			ProviderOptions:   providerOptions,
 			Download: &config.Download{
 				OutputFilePath: outputFile,
+				Offset:         offset,
+				Length:         length,
+				Parallel:       parallel,
+				Hedge:          hedge,
+				Resume:         resume,
+				Force:          force,
 			},
 		}
 
 		umbraInstance, err := umbra.New(cfg)
 		if err != nil {
-			fmt.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
+		defer umbraInstance.Close()
 
 		if err := umbraInstance.Download(context.Background()); err != nil {
+			// standard output may carry the downloaded data
+			fmt.Fprintln(os.Stderr, err)
+			exit(umbraInstance, 1)
+		}
+	},
+}
+
+/*
+ * =====================
+ * Verify Command
+ * =====================
+ */
+
+var verifyCmd = &cobra.Command{
+	Use:     "verify",
+	Aliases: []string{"v"},
+	Short:   "Check every chunk copy of a manifest without restoring the file",
+	PreRunE: func(_ *cobra.Command, _ []string) error {
+		// Validate ghost mode
+		if ghostMode != "" && !ghost.IsValidGhostMode(ghostMode) {
+			return fmt.Errorf("invalid ghost mode %q: must be one of %s", ghostMode, strings.Join(ghost.Modes(), ", "))
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, _ []string) {
+		cfg := &config.Config{
+			ManifestPath:      manifestPath,
+			Password:          password,
+			Quiet:             quiet,
+			GhostMode:         ghostMode,
+			DisabledProviders: disabledProviders,
+			ProviderAliases:   providerAliases,
+			PasteSites:        pasteSites,
+			ProviderOptions:   providerOptions,
+			Verify: &config.Verify{
+				Sample:   sample,
+				Parallel: parallel,
+			},
+		}
+
+		umbraInstance, err := umbra.New(cfg)
+		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		defer umbraInstance.Close()
+
+		report, err := umbraInstance.Verify(context.Background())
+		if err != nil {
+			fmt.Println(err)
+			exit(umbraInstance, 1)
+		}
+
+		switch report.Status() {
+		case umbra.Degraded:
+			exit(umbraInstance, exitDegraded)
+		case umbra.Unrecoverable:
+			exit(umbraInstance, exitUnrecoverable)
+		}
+	},
+}
+
+/*
+ * =====================
+ * Repair Command
+ * =====================
+ */
+
+var repairCmd = &cobra.Command{
+	Use:     "repair",
+	Aliases: []string{"r"},
+	Short:   "Replace the lost chunk copies of a manifest",
+	PreRunE: func(_ *cobra.Command, _ []string) error {
+		if err := validatePlacementFlags(); err != nil {
+			return err
+		}
+
+		// Validate ghost mode
+		if ghostMode != "" && !ghost.IsValidGhostMode(ghostMode) {
+			return fmt.Errorf("invalid ghost mode %q: must be one of %s", ghostMode, strings.Join(ghost.Modes(), ", "))
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, _ []string) {
+		cfg := &config.Config{
+			ManifestPath:      manifestPath,
+			Password:          password,
+			Quiet:             quiet,
+			Providers:         providers,
+			GhostMode:         ghostMode,
+			DisabledProviders: disabledProviders,
+			ProviderAliases:   providerAliases,
+			PasteSites:        pasteSites,
+			ProviderOptions:   providerOptions,
+			Selection:         selectionPolicy,
+			ProviderWeights:   providerWeights,
+			ProviderTags:      providerTags,
+			DistinctTags:      distinctTags,
+			Repair: &config.Repair{
+				Copies:     repairCopies,
+				Parallel:   parallel,
+				Retries:    retries,
+				RetryDelay: retryDelay,
+			},
+		}
+
+		umbraInstance, err := umbra.New(cfg)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer umbraInstance.Close()
+
+		if err := umbraInstance.Repair(context.Background()); err != nil {
+			fmt.Println(err)
+			exit(umbraInstance, 1)
+		}
+	},
+}
+
+/*
+ * =====================
+ * Refresh Command
+ * =====================
+ */
+
+var refreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Upload again the chunk copies of a manifest nearing their expiry",
+	PreRunE: func(_ *cobra.Command, _ []string) error {
+		if err := validatePlacementFlags(); err != nil {
+			return err
+		}
+
+		// Validate ghost mode
+		if ghostMode != "" && !ghost.IsValidGhostMode(ghostMode) {
+			return fmt.Errorf("invalid ghost mode %q: must be one of %s", ghostMode, strings.Join(ghost.Modes(), ", "))
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, _ []string) {
+		cfg := &config.Config{
+			ManifestPath:      manifestPath,
+			Password:          password,
+			Quiet:             quiet,
+			Providers:         providers,
+			GhostMode:         ghostMode,
+			DisabledProviders: disabledProviders,
+			ProviderAliases:   providerAliases,
+			PasteSites:        pasteSites,
+			ProviderOptions:   providerOptions,
+			Selection:         selectionPolicy,
+			ProviderWeights:   providerWeights,
+			ProviderTags:      providerTags,
+			DistinctTags:      distinctTags,
+			Refresh: &config.Refresh{
+				Before:     before,
+				Parallel:   parallel,
+				Retries:    retries,
+				RetryDelay: retryDelay,
+			},
+		}
+
+		umbraInstance, err := umbra.New(cfg)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer umbraInstance.Close()
+
+		if err := umbraInstance.Refresh(context.Background()); err != nil {
+			fmt.Println(err)
+			exit(umbraInstance, 1)
+		}
+	},
+}
+
+/*
+ * =====================
+ * Evacuate Command
+ * =====================
+ */
+
+var evacuateCmd = &cobra.Command{
+	Use:   "evacuate [manifest...]",
+	Short: "Move the chunk copies of one or more manifests off a provider",
+	PreRunE: func(_ *cobra.Command, args []string) error {
+		if len(manifests)+len(args) == 0 {
+			return fmt.Errorf("at least one manifest must be specified")
+		}
+
+		if err := validatePlacementFlags(); err != nil {
+			return err
+		}
+
+		// Validate ghost mode
+		if ghostMode != "" && !ghost.IsValidGhostMode(ghostMode) {
+			return fmt.Errorf("invalid ghost mode %q: must be one of %s", ghostMode, strings.Join(ghost.Modes(), ", "))
+		}
+
+		return nil
+	},
+	Run: func(_ *cobra.Command, args []string) {
+		failed := false
+
+		// manifests are evacuated one by one, a failure does not stop the batch
+		for _, path := range append(manifests, args...) {
+			cfg := &config.Config{
+				ManifestPath:      path,
+				Password:          password,
+				Quiet:             quiet,
+				Providers:         providers,
+				GhostMode:         ghostMode,
+				DisabledProviders: disabledProviders,
+				ProviderAliases:   providerAliases,
+				PasteSites:        pasteSites,
+				ProviderOptions:   providerOptions,
+				Selection:         selectionPolicy,
+				ProviderWeights:   providerWeights,
+				ProviderTags:      providerTags,
+				DistinctTags:      distinctTags,
+				Evacuate: &config.Evacuate{
+					Providers:  evacuated,
+					Parallel:   parallel,
+					Retries:    retries,
+					RetryDelay: retryDelay,
+				},
+			}
+
+			umbraInstance, err := umbra.New(cfg)
+			if err != nil {
+				fmt.Printf("'%s': %v\n", path, err)
+				failed = true
+				continue
+			}
+
+			if err := umbraInstance.Evacuate(context.Background()); err != nil {
+				fmt.Printf("'%s': %v\n", path, err)
+				failed = true
+			}
+			_ = umbraInstance.Close()
+		}
+
+		if failed {
+			os.Exit(1)
+		}
 	},
 }
 
@@ -213,24 +699,25 @@ func init() {
 	/*
 	 * Upload flags
 	 */
-	uploadCmd.Flags().StringVarP(&uploadFile, "file", "f", "", "specify file to upload")
+	uploadCmd.Flags().StringVarP(&uploadFile, "file", "f", "", "specify file to upload or - to read from standard input")
 	uploadCmd.Flags().StringVarP(&password, "password", "p", "", "specify password")
 	uploadCmd.Flags().Int64VarP(&chunkSize, "chunk-size", "s", 0, "specify chunk size in bytes")
-	uploadCmd.Flags().IntVarP(&chunks, "chunks", "c", 3, "specify number of chunks to process")
+	uploadCmd.Flags().StringVarP(&rawChunks, "chunks", "c", "3", "specify number of chunks to process, or auto to size chunks after the provider limits")
 	uploadCmd.Flags().IntVarP(&copies, "copies", "n", 1, "specify number of copies per chunk")
 	uploadCmd.Flags().StringSliceVarP(&providers, "providers", "P", []string{}, "specify list of providers to use")
 	uploadCmd.Flags().StringVarP(&manifestPath, "manifest", "m", "", "specify manifest file to save or provider:<provider> to upload manifest")
 	uploadCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "enable quiet output")
 	uploadCmd.Flags().StringVarP(&ghostMode, "ghost", "g", "", fmt.Sprintf("embed manifest using ghost mode. (%s)", strings.Join(ghost.Modes(), ", ")))
-
-	// Generic provider options - for future use
-	// uploadCmd.Flags().StringSliceVarP(
-	// 	&rawOptions,
-	// 	"option",
-	// 	"o",
-	// 	[]string{},
-	// 	"provider option in key=value form (repeatable)",
-	// )
+	uploadCmd.Flags().IntVarP(&parallel, "parallel", "j", 1, "specify number of chunks uploaded concurrently")
+	uploadCmd.Flags().StringToIntVar(&provParallel, "provider-parallel", map[string]int{}, "cap concurrent uploads per provider in provider=N form (e.g. termbin=2)")
+	uploadCmd.Flags().IntVar(&retries, "retries", 3, "specify number of retries of a failed chunk upload before falling back to another provider")
+	uploadCmd.Flags().DurationVar(&retryDelay, "retry-delay", time.Second, "specify initial delay between upload retries, doubled at every attempt")
+	uploadCmd.Flags().StringVar(&journalPath, "journal", "", "specify journal file recording upload progress (default <manifest>.journal)")
+	uploadCmd.Flags().BoolVar(&resume, "resume", false, "resume an interrupted upload from its journal")
+	uploadCmd.Flags().BoolVar(&abandon, "abandon", false, "list the orphaned copies of an interrupted upload and remove its journal")
+	uploadCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the upload plan without reading the file or contacting any provider")
+	uploadCmd.Flags().BoolVar(&jsonOutput, "json", false, "print the upload plan as JSON, requires --dry-run")
+	uploadCmd.Flags().StringVar(&compression, "compress", "", fmt.Sprintf("compress chunks before encryption. (%s)", strings.Join(compress.Modes(), ", ")))
 
 	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
 	uploadCmd.MarkFlagRequired("file")
@@ -239,24 +726,23 @@ func init() {
 	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
 	uploadCmd.MarkFlagRequired("manifest")
 	uploadCmd.MarkFlagsMutuallyExclusive("chunk-size", "chunks")
+	uploadCmd.MarkFlagsMutuallyExclusive("resume", "abandon")
+	uploadCmd.MarkFlagsMutuallyExclusive("dry-run", "resume", "abandon")
 
 	/*
 	 * Download flags
 	 */
 	downloadCmd.Flags().StringVarP(&manifestPath, "manifest", "m", "", "specify manifest file to read or provider<provider>:<hash> to download from provider")
 	downloadCmd.Flags().StringVarP(&password, "password", "p", "", "specify password")
-	downloadCmd.Flags().StringVarP(&outputFile, "file", "f", "", "specify output file path")
+	downloadCmd.Flags().StringVarP(&outputFile, "file", "f", "", "specify output file path or - to write to standard output")
 	downloadCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "enable quiet output")
 	downloadCmd.Flags().StringVarP(&ghostMode, "ghost", "g", "", fmt.Sprintf("decode manifest from ghost mode. (%s)", strings.Join(ghost.Modes(), ", ")))
-
-	// Generic provider options - for future use
-	// downloadCmd.Flags().StringSliceVarP(
-	// 	&rawOptions,
-	// 	"option",
-	// 	"o",
-	// 	[]string{},
-	// 	"provider option in key=value form (repeatable)",
-	// )
+	downloadCmd.Flags().IntVarP(&parallel, "parallel", "j", 1, "specify number of chunks downloaded concurrently")
+	downloadCmd.Flags().DurationVar(&hedge, "hedge", 0, "request the next chunk copy in parallel after this delay (e.g. 2s, 0 disables hedged reads)")
+	downloadCmd.Flags().BoolVar(&resume, "resume", false, "resume an interrupted download keeping the verified chunks of the partial output file")
+	downloadCmd.Flags().BoolVar(&force, "force", false, "overwrite the output file if it already exists")
+	downloadCmd.Flags().Int64Var(&offset, "offset", 0, "download only the byte range starting at this offset")
+	downloadCmd.Flags().Int64Var(&length, "length", 0, "number of bytes to download starting at offset (0 means up to the end of file)")
 
 	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
 	downloadCmd.MarkFlagRequired("manifest")
@@ -274,9 +760,79 @@ func init() {
 	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
 	infoCmd.MarkFlagRequired("password")
 
+	verifyCmd.Flags().StringVarP(&manifestPath, "manifest", "m", "", "specify manifest file to read or provider<provider>:<hash> to download from provider")
+	verifyCmd.Flags().StringVarP(&password, "password", "p", "", "specify password")
+	verifyCmd.Flags().StringVarP(&ghostMode, "ghost", "g", "", fmt.Sprintf("decode manifest from ghost mode. (%s)", strings.Join(ghost.Modes(), ", ")))
+	verifyCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "enable quiet output, the health is reported by the exit code only")
+	verifyCmd.Flags().IntVarP(&parallel, "parallel", "j", 1, "specify number of chunk copies verified concurrently")
+	verifyCmd.Flags().IntVar(&sample, "sample", 0, "verify only this number of randomly chosen chunks (0 verifies every chunk)")
+
+	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
+	verifyCmd.MarkFlagRequired("manifest")
+	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
+	verifyCmd.MarkFlagRequired("password")
+
+	repairCmd.Flags().StringVarP(&manifestPath, "manifest", "m", "", "specify manifest file to repair or provider<provider>:<hash> to download from provider")
+	repairCmd.Flags().StringVarP(&password, "password", "p", "", "specify password")
+	repairCmd.Flags().StringVarP(&ghostMode, "ghost", "g", "", fmt.Sprintf("decode and encode manifest using ghost mode. (%s)", strings.Join(ghost.Modes(), ", ")))
+	repairCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "enable quiet output")
+	repairCmd.Flags().IntVarP(&repairCopies, "copies", "n", 0, "specify number of copies per chunk (0 keeps the number recorded in the manifest)")
+	repairCmd.Flags().StringSliceVarP(&providers, "providers", "P", []string{}, "specify list of providers to store the new copies on")
+	repairCmd.Flags().IntVarP(&parallel, "parallel", "j", 1, "specify number of chunks repaired concurrently")
+	repairCmd.Flags().IntVar(&retries, "retries", 3, "specify number of retries of a failed chunk upload before falling back to another provider")
+	repairCmd.Flags().DurationVar(&retryDelay, "retry-delay", time.Second, "specify initial delay between upload retries, doubled at every attempt")
+
+	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
+	repairCmd.MarkFlagRequired("manifest")
+	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
+	repairCmd.MarkFlagRequired("password")
+
+	refreshCmd.Flags().StringVarP(&manifestPath, "manifest", "m", "", "specify manifest file to refresh or provider<provider>:<hash> to download from provider")
+	refreshCmd.Flags().StringVarP(&password, "password", "p", "", "specify password")
+	refreshCmd.Flags().StringVarP(&ghostMode, "ghost", "g", "", fmt.Sprintf("decode and encode manifest using ghost mode. (%s)", strings.Join(ghost.Modes(), ", ")))
+	refreshCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "enable quiet output")
+	refreshCmd.Flags().DurationVar(&before, "before", 48*time.Hour, "upload again the copies expiring within this time")
+	refreshCmd.Flags().StringSliceVarP(&providers, "providers", "P", []string{}, "specify list of providers to store the new copies on")
+	refreshCmd.Flags().IntVarP(&parallel, "parallel", "j", 1, "specify number of chunks refreshed concurrently")
+	refreshCmd.Flags().IntVar(&retries, "retries", 3, "specify number of retries of a failed chunk upload before falling back to another provider")
+	refreshCmd.Flags().DurationVar(&retryDelay, "retry-delay", time.Second, "specify initial delay between upload retries, doubled at every attempt")
+
+	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
+	refreshCmd.MarkFlagRequired("manifest")
+	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
+	refreshCmd.MarkFlagRequired("password")
+
+	evacuateCmd.Flags().StringSliceVarP(&manifests, "manifest", "m", []string{}, "specify manifest files to update or provider<provider>:<hash> to download from provider (repeatable)")
+	evacuateCmd.Flags().StringSliceVar(&evacuated, "provider", []string{}, "specify providers to move the copies off (repeatable)")
+	evacuateCmd.Flags().StringSliceVarP(&providers, "providers", "P", []string{}, "specify list of providers to move the copies to")
+	evacuateCmd.Flags().StringVarP(&password, "password", "p", "", "specify password shared by the manifests")
+	evacuateCmd.Flags().StringVarP(&ghostMode, "ghost", "g", "", fmt.Sprintf("decode and encode manifests using ghost mode. (%s)", strings.Join(ghost.Modes(), ", ")))
+	evacuateCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "enable quiet output")
+	evacuateCmd.Flags().IntVarP(&parallel, "parallel", "j", 1, "specify number of chunks moved concurrently")
+	evacuateCmd.Flags().IntVar(&retries, "retries", 3, "specify number of retries of a failed chunk upload before falling back to another provider")
+	evacuateCmd.Flags().DurationVar(&retryDelay, "retry-delay", time.Second, "specify initial delay between upload retries, doubled at every attempt")
+
+	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
+	evacuateCmd.MarkFlagRequired("provider")
+	//nolint:errcheck // MarkFlagRequired only errors if flag doesn't exist, which is impossible here
+	evacuateCmd.MarkFlagRequired("password")
+
+	for _, cmd := range []*cobra.Command{uploadCmd, repairCmd, refreshCmd, evacuateCmd} {
+		addPlacementFlags(cmd)
+	}
+
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "specify configuration file of the providers (default <user config dir>/umbra/config.json)")
+	rootCmd.PersistentFlags().StringArrayVarP(&rawOptions, "option", "o", []string{}, "set a provider option in provider.key=value form (repeatable, e.g. clbin.base_url=https://clbin.example)")
+	rootCmd.PersistentFlags().StringSliceVar(&disabledProviders, "disable-provider", []string{}, "disable providers, also when named by an alias")
+	rootCmd.PersistentFlags().StringToStringVar(&providerAliases, "provider-alias", map[string]string{}, "name a provider instance in alias=provider form (e.g. mirror=clbin), copies are recorded under the alias")
+
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(providersCmd)
 	rootCmd.AddCommand(infoCmd)
 	rootCmd.AddCommand(uploadCmd)
 	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(repairCmd)
+	rootCmd.AddCommand(refreshCmd)
+	rootCmd.AddCommand(evacuateCmd)
 }
@@ -1,6 +1,22 @@
 package config
 
-import "github.com/henomis/umbra/internal/ghost"
+import (
+	"fmt"
+	"time"
+
+	"github.com/henomis/umbra/internal/compress"
+	"github.com/henomis/umbra/internal/ghost"
+	"github.com/henomis/umbra/internal/provider/paste"
+	"github.com/henomis/umbra/internal/selection"
+)
+
+// Stdio is the file path selecting standard input for uploads and standard
+// output for downloads.
+const Stdio = "-"
+
+// AutoChunks is the Upload.Chunks value sizing the chunks after the smallest
+// provider capacity, instead of splitting the file in a fixed number of chunks.
+const AutoChunks = -1
 
 // Config holds the configuration for the application.
 type Config struct {
@@ -8,11 +24,33 @@ type Config struct {
 	Password     string
 	Quiet        bool
 	Providers    []string
-	// Options      map[string]string // for future use
-	GhostMode string
+	// ProviderOptions maps provider names, or aliases, to their options.
+	ProviderOptions map[string]map[string]string
+	GhostMode       string
+	// DisabledProviders are never used, even when named by an alias.
+	DisabledProviders []string
+	// ProviderAliases maps alias names to registered provider names. Copies
+	// stored through an alias are recorded under the alias.
+	ProviderAliases map[string]string
+	// PasteSites maps provider names to the declaration of the paste service
+	// they use, for services not built in.
+	PasteSites map[string]paste.Spec
+
+	// Selection is the policy choosing the provider of each new chunk copy.
+	Selection       string
+	ProviderWeights map[string]int
+	// ProviderTags maps provider names to their tags, such as jurisdiction.
+	ProviderTags map[string]map[string]string
+	// DistinctTags are the tag keys whose value must differ between the
+	// copies of a chunk.
+	DistinctTags []string
 
 	Upload   *Upload
 	Download *Download
+	Verify   *Verify
+	Repair   *Repair
+	Refresh  *Refresh
+	Evacuate *Evacuate
 }
 
 // Upload holds the upload-specific configuration.
@@ -21,11 +59,84 @@ type Upload struct {
 	ChunkSize     int64
 	Chunks        int
 	Copies        int
+	Compress      string
+	Parallel      int
+	Retries       int
+	RetryDelay    time.Duration
+	JournalPath   string
+	Resume        bool
+	Abandon       bool
+	// DryRun prints the upload plan instead of uploading, as JSON when JSON
+	// is set.
+	DryRun bool
+	JSON   bool
+	// ProviderParallel caps concurrent uploads per provider name.
+	ProviderParallel map[string]int
+}
+
+// IsStream reports whether the input is read from standard input.
+func (u *Upload) IsStream() bool {
+	return u.InputFilePath == Stdio
 }
 
 // Download holds the download-specific configuration.
 type Download struct {
 	OutputFilePath string
+	Offset         int64
+	Length         int64
+	Parallel       int
+	Resume         bool
+	Force          bool
+	// Hedge is the delay after which the next chunk copy is requested in
+	// parallel. Zero disables hedged reads.
+	Hedge time.Duration
+}
+
+// IsStream reports whether the output is written to standard output.
+func (d *Download) IsStream() bool {
+	return d.OutputFilePath == Stdio
+}
+
+// IsRange reports whether only a byte range of the file must be downloaded.
+func (d *Download) IsRange() bool {
+	return d.Offset != 0 || d.Length != 0
+}
+
+// Verify holds the verify-specific configuration.
+type Verify struct {
+	// Sample is the number of randomly chosen chunks to verify. Zero
+	// verifies every chunk.
+	Sample   int
+	Parallel int
+}
+
+// Repair holds the repair-specific configuration.
+type Repair struct {
+	// Copies is the number of intact copies each chunk must have. Zero keeps
+	// the number of copies recorded in the manifest.
+	Copies     int
+	Parallel   int
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// Refresh holds the refresh-specific configuration.
+type Refresh struct {
+	// Before is the time ahead of their expiry within which copies are
+	// uploaded again.
+	Before     time.Duration
+	Parallel   int
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// Evacuate holds the evacuate-specific configuration.
+type Evacuate struct {
+	// Providers are the providers the copies are moved off.
+	Providers  []string
+	Parallel   int
+	Retries    int
+	RetryDelay time.Duration
 }
 
 // Validate checks the configuration for validity.
@@ -39,8 +150,40 @@ func (c *Config) Validate() error {
 		return ErrInvalidPassword
 	}
 
+	if !selection.IsValidPolicy(c.Selection) {
+		return ErrInvalidSelection
+	}
+
+	for alias, name := range c.ProviderAliases {
+		if alias == "" || name == "" {
+			return ErrInvalidProviderAlias
+		}
+	}
+
+	for name, spec := range c.PasteSites {
+		if name == "" {
+			return ErrInvalidPasteSite
+		}
+		if err := spec.Validate(); err != nil {
+			return fmt.Errorf("%w %s: %w", ErrInvalidPasteSite, name, err)
+		}
+	}
+
+	for _, weight := range c.ProviderWeights {
+		if weight <= 0 {
+			return ErrInvalidWeight
+		}
+	}
+
 	// Mode-specific validations
-	if c.Upload != nil && c.Download != nil {
+	modes := 0
+	for _, set := range []bool{c.Upload != nil, c.Download != nil, c.Verify != nil, c.Repair != nil, c.Refresh != nil, c.Evacuate != nil} {
+		if set {
+			modes++
+		}
+	}
+
+	if modes > 1 {
 		return ErrInvalidMode
 	}
 
@@ -53,12 +196,40 @@ func (c *Config) Validate() error {
 			return ErrInvalidChunkConfig
 		} else if c.Upload.ChunkSize != 0 && c.Upload.Chunks != 0 {
 			return ErrInvalidChunkConfig
+		} else if c.Upload.ChunkSize < 0 || (c.Upload.Chunks < 0 && c.Upload.Chunks != AutoChunks) {
+			return ErrInvalidChunkConfig
 		}
 
 		if c.Upload.Copies <= 0 {
 			return ErrInvalidCopies
 		}
 
+		if c.Upload.Compress != "" && !compress.IsValidMode(c.Upload.Compress) {
+			return ErrInvalidCompression
+		}
+
+		if c.Upload.Resume && c.Upload.Abandon {
+			return ErrInvalidResume
+		}
+
+		if c.Upload.DryRun && (c.Upload.Resume || c.Upload.Abandon) {
+			return ErrInvalidDryRun
+		}
+
+		if c.Upload.Parallel < 0 {
+			return ErrInvalidParallel
+		}
+
+		if c.Upload.Retries < 0 || c.Upload.RetryDelay < 0 {
+			return ErrInvalidRetries
+		}
+
+		for _, limit := range c.Upload.ProviderParallel {
+			if limit <= 0 {
+				return ErrInvalidParallel
+			}
+		}
+
 		if c.GhostMode != "" && !ghost.IsValidGhostMode(c.GhostMode) {
 			return ErrInvalidGhostMode
 		}
@@ -70,6 +241,94 @@ func (c *Config) Validate() error {
 			return ErrInvalidOutputFilePath
 		}
 
+		if c.Download.Offset < 0 || c.Download.Length < 0 {
+			return ErrInvalidRange
+		}
+
+		if c.Download.Parallel < 0 {
+			return ErrInvalidParallel
+		}
+
+		if c.Download.Hedge < 0 {
+			return ErrInvalidHedge
+		}
+
+		if c.Download.Resume && (c.Download.IsStream() || c.Download.IsRange()) {
+			return ErrInvalidDownloadResume
+		}
+
+		if c.GhostMode != "" && !ghost.IsValidGhostMode(c.GhostMode) {
+			return ErrInvalidGhostMode
+		}
+	}
+
+	if c.Verify != nil {
+		// Verify-specific validations
+		if c.Verify.Sample < 0 {
+			return ErrInvalidSample
+		}
+
+		if c.Verify.Parallel < 0 {
+			return ErrInvalidParallel
+		}
+
+		if c.GhostMode != "" && !ghost.IsValidGhostMode(c.GhostMode) {
+			return ErrInvalidGhostMode
+		}
+	}
+
+	if c.Repair != nil {
+		// Repair-specific validations
+		if c.Repair.Copies < 0 {
+			return ErrInvalidCopies
+		}
+
+		if c.Repair.Parallel < 0 {
+			return ErrInvalidParallel
+		}
+
+		if c.Repair.Retries < 0 || c.Repair.RetryDelay < 0 {
+			return ErrInvalidRetries
+		}
+
+		if c.GhostMode != "" && !ghost.IsValidGhostMode(c.GhostMode) {
+			return ErrInvalidGhostMode
+		}
+	}
+
+	if c.Refresh != nil {
+		// Refresh-specific validations
+		if c.Refresh.Before < 0 {
+			return ErrInvalidRefreshBefore
+		}
+
+		if c.Refresh.Parallel < 0 {
+			return ErrInvalidParallel
+		}
+
+		if c.Refresh.Retries < 0 || c.Refresh.RetryDelay < 0 {
+			return ErrInvalidRetries
+		}
+
+		if c.GhostMode != "" && !ghost.IsValidGhostMode(c.GhostMode) {
+			return ErrInvalidGhostMode
+		}
+	}
+
+	if c.Evacuate != nil {
+		// Evacuate-specific validations
+		if len(c.Evacuate.Providers) == 0 {
+			return ErrInvalidEvacuate
+		}
+
+		if c.Evacuate.Parallel < 0 {
+			return ErrInvalidParallel
+		}
+
+		if c.Evacuate.Retries < 0 || c.Evacuate.RetryDelay < 0 {
+			return ErrInvalidRetries
+		}
+
 		if c.GhostMode != "" && !ghost.IsValidGhostMode(c.GhostMode) {
 			return ErrInvalidGhostMode
 		}
@@ -6,10 +6,26 @@ import "fmt"
 var (
 	ErrInvalidInputFilePath  = fmt.Errorf("input file path must not be empty")
 	ErrInvalidOutputFilePath = fmt.Errorf("output file path must not be empty")
-	ErrInvalidMode           = fmt.Errorf("either download or upload mode must be specified")
+	ErrInvalidMode           = fmt.Errorf("only one of upload, download, verify, repair, refresh or evacuate mode can be specified")
 	ErrInvalidChunkConfig    = fmt.Errorf("either ChunkSize or Chunks must be specified")
 	ErrInvalidCopies         = fmt.Errorf("copies must be a positive integer")
 	ErrInvalidPassword       = fmt.Errorf("password must not be empty")
 	ErrInvalidManifestPath   = fmt.Errorf("manifest path must not be empty")
 	ErrInvalidGhostMode      = fmt.Errorf("invalid ghost mode specified")
+	ErrInvalidCompression    = fmt.Errorf("invalid compression mode specified")
+	ErrInvalidRange          = fmt.Errorf("offset and length must not be negative")
+	ErrInvalidHedge          = fmt.Errorf("hedge delay must not be negative")
+	ErrInvalidRetries        = fmt.Errorf("retries and retry delay must not be negative")
+	ErrInvalidResume         = fmt.Errorf("resume and abandon cannot be used together")
+	ErrInvalidDryRun         = fmt.Errorf("dry run cannot be used together with resume or abandon")
+	ErrInvalidDownloadResume = fmt.Errorf("resume requires a whole file download to a regular file")
+	ErrInvalidParallel       = fmt.Errorf("parallel transfers must be a positive integer")
+	ErrInvalidSample         = fmt.Errorf("sample size must not be negative")
+	ErrInvalidRefreshBefore  = fmt.Errorf("refresh time before expiry must not be negative")
+	ErrInvalidEvacuate       = fmt.Errorf("at least one provider to evacuate must be specified")
+	ErrInvalidSelection      = fmt.Errorf("invalid provider selection policy specified")
+	ErrInvalidProviderAlias  = fmt.Errorf("provider aliases must name a provider")
+	ErrInvalidConfigFile     = fmt.Errorf("invalid configuration file")
+	ErrInvalidPasteSite      = fmt.Errorf("invalid paste site")
+	ErrInvalidWeight         = fmt.Errorf("provider weights must be positive integers")
 )
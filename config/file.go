@@ -0,0 +1,92 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/henomis/umbra/internal/provider/paste"
+)
+
+// File holds the provider settings read from a configuration file. Command
+// line flags take precedence over it.
+type File struct {
+	// Options maps provider names, or aliases, to their options.
+	Options  map[string]map[string]string `json:"options"`
+	Aliases  map[string]string            `json:"aliases"`
+	Disabled []string                     `json:"disabled"`
+	// Pastes declares the paste services used through the generic paste
+	// provider, by provider name.
+	Pastes map[string]paste.Spec `json:"pastes"`
+}
+
+// DefaultFilePath returns the path of the configuration file read when none
+// is given, umbra/config.json in the user configuration directory.
+func DefaultFilePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "umbra", "config.json"), nil
+}
+
+// LoadFile reads the JSON configuration file at path. When optional is set, a
+// missing file yields an empty configuration.
+func LoadFile(path string, optional bool) (*File, error) {
+	data, err := os.ReadFile(path)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%w '%s': %w", ErrInvalidConfigFile, path, err)
+	}
+
+	return &f, nil
+}
+
+// Apply adds the file settings to c, keeping the ones already set in c.
+func (f *File) Apply(c *Config) {
+	for name, options := range f.Options {
+		if c.ProviderOptions == nil {
+			c.ProviderOptions = make(map[string]map[string]string)
+		}
+		if c.ProviderOptions[name] == nil {
+			c.ProviderOptions[name] = make(map[string]string)
+		}
+
+		for key, value := range options {
+			if _, ok := c.ProviderOptions[name][key]; !ok {
+				c.ProviderOptions[name][key] = value
+			}
+		}
+	}
+
+	for alias, name := range f.Aliases {
+		if c.ProviderAliases == nil {
+			c.ProviderAliases = make(map[string]string)
+		}
+		if _, ok := c.ProviderAliases[alias]; !ok {
+			c.ProviderAliases[alias] = name
+		}
+	}
+
+	for name, spec := range f.Pastes {
+		if c.PasteSites == nil {
+			c.PasteSites = make(map[string]paste.Spec)
+		}
+		if _, ok := c.PasteSites[name]; !ok {
+			c.PasteSites[name] = spec
+		}
+	}
+
+	c.DisabledProviders = append(c.DisabledProviders, f.Disabled...)
+}
@@ -4,6 +4,7 @@ go 1.25.5
 
 require (
 	github.com/auyer/steganography v1.0.3
+	github.com/klauspost/compress v1.18.0
 	github.com/makiuchi-d/gozxing v0.1.1
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/spf13/cobra v1.10.2
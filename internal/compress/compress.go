@@ -0,0 +1,149 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec represents the compression algorithm applied to a chunk.
+type Codec = string
+
+// Supported codecs. None is the zero value and means the chunk is stored raw.
+const (
+	None Codec = ""
+	Gzip Codec = "gzip"
+	Zstd Codec = "zstd"
+	Auto Codec = "auto"
+)
+
+var modes = []Codec{Zstd, Gzip, Auto}
+
+// Modes returns the list of supported compression modes.
+func Modes() []Codec {
+	return modes
+}
+
+// IsValidMode checks if the provided mode is a valid compression mode.
+func IsValidMode(mode string) bool {
+	return slices.Contains(modes, mode)
+}
+
+// Bound returns the largest size of n bytes once compressed with the given
+// mode, reached by incompressible data.
+func Bound(mode string, n int64) int64 {
+	switch mode {
+	case Gzip:
+		// stored deflate blocks of up to 16 KiB with 5 bytes headers, plus
+		// the gzip header and trailer
+		return n + 5*(n/16384+1) + 64
+	case Zstd:
+		// zstd compress bound, plus the frame header and checksum
+		bound := n + n>>8
+		if n < 128<<10 {
+			bound += (128<<10 - n) >> 11
+		}
+		return bound + 64
+	default:
+		// Auto stores incompressible data raw
+		return n
+	}
+}
+
+// Compress compresses data using the given mode and returns the compressed data
+// together with the codec actually applied. In Auto mode data is compressed with
+// zstd and returned raw, with codec None, when compression does not shrink it.
+func Compress(mode string, data []byte) ([]byte, Codec, error) {
+	switch mode {
+	case None:
+		return data, None, nil
+	case Gzip:
+		compressed, err := gzipCompress(data)
+		return compressed, Gzip, err
+	case Zstd:
+		compressed, err := zstdCompress(data)
+		return compressed, Zstd, err
+	case Auto:
+		compressed, err := zstdCompress(data)
+		if err != nil {
+			return nil, None, err
+		}
+		if len(compressed) >= len(data) {
+			return data, None, nil
+		}
+		return compressed, Zstd, nil
+	default:
+		return nil, None, fmt.Errorf("%w: %q", ErrUnsupportedCodec, mode)
+	}
+}
+
+// Decompress decompresses data encoded with the given codec. maxSize bounds the
+// decompressed output to protect against decompression bombs.
+func Decompress(codec Codec, data []byte, maxSize int64) ([]byte, error) {
+	var r io.Reader
+
+	switch codec {
+	case None:
+		return data, nil
+	case Gzip:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case Zstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedCodec, codec)
+	}
+
+	out, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(out)) > maxSize {
+		return nil, ErrSizeExceeded
+	}
+
+	return out, nil
+}
+
+func gzipCompress(data []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+
+	w, err := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func zstdCompress(data []byte) ([]byte, error) {
+	w, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	return w.EncodeAll(data, nil), nil
+}
@@ -0,0 +1,100 @@
+package compress
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestCompressDecompressRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("umbra compressible payload "), 512)
+
+	for _, mode := range []Codec{Gzip, Zstd, Auto} {
+		compressed, codec, err := Compress(mode, data)
+		if err != nil {
+			t.Fatalf("Compress(%q) returned error: %v", mode, err)
+		}
+
+		if codec == None {
+			t.Fatalf("Compress(%q) did not compress compressible data", mode)
+		}
+
+		if len(compressed) >= len(data) {
+			t.Fatalf("Compress(%q) size = %d, want < %d", mode, len(compressed), len(data))
+		}
+
+		decompressed, err := Decompress(codec, compressed, int64(len(data)))
+		if err != nil {
+			t.Fatalf("Decompress(%q) returned error: %v", codec, err)
+		}
+
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("Decompress(%q) mismatch", codec)
+		}
+	}
+}
+
+func TestCompressAutoSkipsIncompressibleData(t *testing.T) {
+	data := make([]byte, 4096)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("rand.Read returned error: %v", err)
+	}
+
+	compressed, codec, err := Compress(Auto, data)
+	if err != nil {
+		t.Fatalf("Compress returned error: %v", err)
+	}
+
+	if codec != None {
+		t.Fatalf("codec = %q, want none", codec)
+	}
+
+	if !bytes.Equal(compressed, data) {
+		t.Fatal("Compress should return raw data when compression does not help")
+	}
+}
+
+func TestDecompressRejectsOversizedOutput(t *testing.T) {
+	data := bytes.Repeat([]byte{0}, 1024)
+
+	compressed, codec, err := Compress(Zstd, data)
+	if err != nil {
+		t.Fatalf("Compress returned error: %v", err)
+	}
+
+	_, err = Decompress(codec, compressed, int64(len(data))-1)
+	if !errors.Is(err, ErrSizeExceeded) {
+		t.Fatalf("Decompress error = %v, want %v", err, ErrSizeExceeded)
+	}
+}
+
+func TestCompressUnsupportedCodec(t *testing.T) {
+	if _, _, err := Compress("lz4", []byte("data")); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Fatalf("Compress error = %v, want %v", err, ErrUnsupportedCodec)
+	}
+
+	if _, err := Decompress("lz4", []byte("data"), 4); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Fatalf("Decompress error = %v, want %v", err, ErrUnsupportedCodec)
+	}
+}
+
+func TestBoundHoldsForIncompressibleData(t *testing.T) {
+	for _, size := range []int{0, 1, 1000, 100 << 10, 1 << 20} {
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, mode := range []Codec{None, Gzip, Zstd, Auto} {
+			compressed, _, err := Compress(mode, data)
+			if err != nil {
+				t.Fatalf("Compress(%q) returned error: %v", mode, err)
+			}
+
+			if bound := Bound(mode, int64(size)); int64(len(compressed)) > bound {
+				t.Fatalf("Compress(%q) of %d bytes = %d bytes, above bound %d", mode, size, len(compressed), bound)
+			}
+		}
+	}
+}
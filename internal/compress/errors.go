@@ -0,0 +1,9 @@
+package compress
+
+import "errors"
+
+// Compression errors.
+var (
+	ErrUnsupportedCodec = errors.New("compress: unsupported codec")
+	ErrSizeExceeded     = errors.New("compress: decompressed size exceeds expected size")
+)
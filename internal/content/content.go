@@ -2,9 +2,8 @@
 package content
 
 import (
-	"bytes"
-	"crypto/sha256"
 	"encoding/json"
+	"time"
 )
 
 // Meta represents provider-specific metadata for a chunk copy.
@@ -12,23 +11,56 @@ type Meta = json.RawMessage
 
 // Content represents a file fragmented into ordered chunks.
 type Content struct {
-	Hash   [32]byte `json:"hash"`   // FileHash stores the overall file hash.
-	Size   int64    `json:"size"`   // Size holds the original file size in bytes.
-	Chunks []Chunk  `json:"chunks"` // Chunks holds the chunk sequence.
+	Hash   [32]byte  `json:"hash"`           // FileHash stores the SHA-256 of the whole file.
+	Root   [32]byte  `json:"root,omitzero"`  // Root stores the Merkle root over the chunk hashes.
+	Size   int64     `json:"size"`           // Size holds the original file size in bytes.
+	Chunks []Chunk   `json:"chunks"`         // Chunks holds the chunk sequence.
+	Saved  time.Time `json:"saved,omitzero"` // Saved is when the manifest was last saved.
 }
 
 // Chunk represents a single chunk of the file.
 type Chunk struct {
-	ID     uint32      `json:"id"`
-	Hash   [32]byte    `json:"hash"`
-	Size   int64       `json:"size"`
-	Copies []ChunkCopy `json:"copies"`
+	ID          uint32      `json:"id"`
+	Hash        [32]byte    `json:"hash"`
+	Size        int64       `json:"size"`
+	Compression string      `json:"compression,omitempty"` // Compression is the codec applied before encryption.
+	Zero        bool        `json:"zero,omitempty"`        // Zero marks an all-zero chunk that is not stored.
+	Ref         uint32      `json:"ref,omitempty"`         // Ref is the ID of an earlier chunk holding identical data.
+	Copies      []ChunkCopy `json:"copies"`
 }
 
 // ChunkCopy represents a redundant copy of a chunk stored by a provider.
 type ChunkCopy struct {
-	Provider string `json:"provider"`
-	Meta     Meta   `json:"meta"`
+	Provider string    `json:"provider"`
+	Meta     Meta      `json:"meta"`
+	Uploaded time.Time `json:"uploaded,omitzero"` // Uploaded is when the copy was stored.
+	Expires  time.Time `json:"expires,omitzero"`  // Expires is when the provider may drop the copy, zero if never.
+}
+
+// NewChunkCopy returns a copy stored by provider at uploaded, which the
+// provider keeps for expire. A zero expire means the copy does not expire.
+func NewChunkCopy(provider string, meta Meta, uploaded time.Time, expire time.Duration) ChunkCopy {
+	c := ChunkCopy{
+		Provider: provider,
+		Meta:     meta,
+		Uploaded: uploaded.UTC().Truncate(time.Second),
+	}
+
+	if expire > 0 {
+		c.Expires = c.Uploaded.Add(expire)
+	}
+
+	return c
+}
+
+// ExpiresBefore reports whether the provider may drop the copy before t.
+// Copies recorded without their upload time are assumed to.
+func (c ChunkCopy) ExpiresBefore(t time.Time) bool {
+	if c.Uploaded.IsZero() {
+		return true
+	}
+
+	return !c.Expires.IsZero() && c.Expires.Before(t)
 }
 
 // New returns a new Content holding the provided file hash.
@@ -40,23 +72,59 @@ func New(fileHash [32]byte, size int64) *Content {
 	}
 }
 
-// Add stores chunk data and metadata, optionally creating a new chunk ID.
+// Add stores chunk data and a copy of it, optionally creating a new chunk ID.
 // If chunkID is nil, the method assigns the next incremental ID.
-func (c *Content) Add(chunkHash [32]byte, size int64, provider string, chunkID *uint32, meta Meta) uint32 {
+func (c *Content) Add(chunkHash [32]byte, size int64, compression string, chunkID *uint32, chunkCopy ChunkCopy) uint32 {
 	id := c.nextChunkID()
 	if chunkID != nil {
 		id = *chunkID
 	}
 
 	if idx := c.chunkIndex(id); idx >= 0 {
-		c.Chunks[idx].Copies = append(c.Chunks[idx].Copies, ChunkCopy{Provider: provider, Meta: meta})
+		c.Chunks[idx].Copies = append(c.Chunks[idx].Copies, chunkCopy)
 		return id
 	}
 
-	c.appendChunk(id, chunkHash, size, provider, meta)
+	c.appendChunk(id, chunkHash, size, compression, chunkCopy)
+	return id
+}
+
+// AddZero stores an all-zero chunk, which is not uploaded to any provider.
+func (c *Content) AddZero(chunkHash [32]byte, size int64) uint32 {
+	id := c.nextChunkID()
+	c.Chunks = append(c.Chunks, Chunk{
+		ID:     id,
+		Hash:   chunkHash,
+		Size:   size,
+		Zero:   true,
+		Copies: []ChunkCopy{},
+	})
+	return id
+}
+
+// AddRef stores a chunk whose data is identical to the earlier chunk ref, which
+// is downloaded in its place.
+func (c *Content) AddRef(chunkHash [32]byte, size int64, ref uint32) uint32 {
+	id := c.nextChunkID()
+	c.Chunks = append(c.Chunks, Chunk{
+		ID:     id,
+		Hash:   chunkHash,
+		Size:   size,
+		Ref:    ref,
+		Copies: []ChunkCopy{},
+	})
 	return id
 }
 
+// Chunk returns the chunk with the given ID.
+func (c *Content) Chunk(id uint32) (*Chunk, bool) {
+	idx := c.chunkIndex(id)
+	if idx < 0 {
+		return nil, false
+	}
+	return &c.Chunks[idx], true
+}
+
 // Encode marshals Content into JSON.
 func (c *Content) Encode() ([]byte, error) {
 	return json.Marshal(c)
@@ -69,18 +137,10 @@ func NewFromData(data []byte) (*Content, error) {
 	return &c, err
 }
 
-// ComputeFileHash derives the file hash by concatenating chunk hashes in order.
-func (c *Content) ComputeFileHash() []byte {
-	h := sha256.New()
-	for _, chunk := range c.Chunks {
-		h.Write(chunk.Hash[:])
-	}
-	return h.Sum(nil)
-}
-
-// VerifyFileHash returns true when the stored hash matches the computed one.
-func (c *Content) VerifyFileHash() bool {
-	return bytes.Equal(c.ComputeFileHash(), c.Hash[:])
+// HasRoot reports whether the content carries a Merkle root. Manifests created
+// before Merkle roots were introduced only hold the whole file hash.
+func (c *Content) HasRoot() bool {
+	return c.Root != [32]byte{}
 }
 
 func (c *Content) nextChunkID() uint32 {
@@ -99,13 +159,12 @@ func (c *Content) chunkIndex(id uint32) int {
 	return -1
 }
 
-func (c *Content) appendChunk(id uint32, chunkHash [32]byte, size int64, provider string, meta Meta) {
+func (c *Content) appendChunk(id uint32, chunkHash [32]byte, size int64, compression string, chunkCopy ChunkCopy) {
 	c.Chunks = append(c.Chunks, Chunk{
-		ID:   id,
-		Hash: chunkHash,
-		Size: size,
-		Copies: []ChunkCopy{
-			{Provider: provider, Meta: meta},
-		},
+		ID:          id,
+		Hash:        chunkHash,
+		Size:        size,
+		Compression: compression,
+		Copies:      []ChunkCopy{chunkCopy},
 	})
 }
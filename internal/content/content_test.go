@@ -0,0 +1,46 @@
+package content
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestChunkCopyExpiresBefore(t *testing.T) {
+	uploaded := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+
+	tests := []struct {
+		name string
+		copy ChunkCopy
+		t    time.Time
+		want bool
+	}{
+		{"before expiry", NewChunkCopy("p", nil, uploaded, week), uploaded.Add(week - time.Hour), false},
+		{"after expiry", NewChunkCopy("p", nil, uploaded, week), uploaded.Add(week + time.Hour), true},
+		{"never expires", NewChunkCopy("p", nil, uploaded, 0), uploaded.Add(100 * week), false},
+		{"unknown upload time", ChunkCopy{Provider: "p"}, uploaded, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.copy.ExpiresBefore(tt.t); got != tt.want {
+				t.Fatalf("ExpiresBefore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkCopyJSONOmitsUnknownTimes(t *testing.T) {
+	c := New([32]byte{}, 0)
+	c.Add([32]byte{1}, 1, "", nil, ChunkCopy{Provider: "p", Meta: Meta(`{}`)})
+
+	data, err := c.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(data, []byte(`"uploaded"`)) || bytes.Contains(data, []byte(`"expires"`)) {
+		t.Fatalf("unexpected times in %s", data)
+	}
+}
@@ -0,0 +1,67 @@
+package content
+
+import "crypto/sha256"
+
+// Merkle tree hashing follows RFC 6962: leaves and interior nodes are domain
+// separated by a one byte prefix and a tree of n leaves is split at the largest
+// power of two smaller than n.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// ComputeRoot derives the Merkle root over the ordered chunk hashes.
+func (c *Content) ComputeRoot() [32]byte {
+	return MerkleRoot(c.leaves())
+}
+
+// VerifyRoot returns true when the stored Merkle root matches the one computed
+// from the chunk hashes.
+func (c *Content) VerifyRoot() bool {
+	return c.ComputeRoot() == c.Root
+}
+
+// MerkleRoot computes the RFC 6962 Merkle tree hash of the given leaves.
+func MerkleRoot(leaves [][32]byte) [32]byte {
+	switch len(leaves) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return leafHash(leaves[0])
+	}
+
+	k := splitPoint(len(leaves))
+	return nodeHash(MerkleRoot(leaves[:k]), MerkleRoot(leaves[k:]))
+}
+
+func (c *Content) leaves() [][32]byte {
+	leaves := make([][32]byte, len(c.Chunks))
+	for i, chunk := range c.Chunks {
+		leaves[i] = chunk.Hash
+	}
+	return leaves
+}
+
+// splitPoint returns the largest power of two smaller than n.
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func leafHash(leaf [32]byte) [32]byte {
+	var buf [1 + 32]byte
+	buf[0] = merkleLeafPrefix
+	copy(buf[1:], leaf[:])
+	return sha256.Sum256(buf[:])
+}
+
+func nodeHash(left, right [32]byte) [32]byte {
+	var buf [1 + 32 + 32]byte
+	buf[0] = merkleNodePrefix
+	copy(buf[1:], left[:])
+	copy(buf[33:], right[:])
+	return sha256.Sum256(buf[:])
+}
@@ -0,0 +1,38 @@
+package content
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func newTestContent(n int) *Content {
+	c := New([32]byte{}, 0)
+	for i := range n {
+		hash := sha256.Sum256(fmt.Appendf(nil, "chunk-%d", i))
+		c.Add(hash, 1, "", nil, ChunkCopy{Provider: "provider", Meta: Meta(`{}`)})
+	}
+	c.Root = c.ComputeRoot()
+	return c
+}
+
+func TestMerkleRootSingleLeaf(t *testing.T) {
+	c := newTestContent(1)
+
+	if c.Root != leafHash(c.Chunks[0].Hash) {
+		t.Fatalf("root = %x, want leaf hash", c.Root)
+	}
+}
+
+func TestMerkleRootChangesWithChunks(t *testing.T) {
+	c := newTestContent(5)
+
+	if !c.VerifyRoot() {
+		t.Fatal("VerifyRoot returned false for untouched content")
+	}
+
+	c.Chunks[3].Hash[0] ^= 0xff
+	if c.VerifyRoot() {
+		t.Fatal("VerifyRoot returned true after a chunk hash was modified")
+	}
+}
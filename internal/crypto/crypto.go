@@ -2,6 +2,7 @@ package crypto
 
 import (
 	"crypto/rand"
+	"sync"
 
 	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/chacha20poly1305"
@@ -11,12 +12,21 @@ import (
 const (
 	KDFArgon2id             = 1
 	CipherXChaCha20Poly1305 = 1
+
+	// Overhead is the number of bytes Encode adds to the content.
+	Overhead = chacha20poly1305.Overhead
 )
 
 // Crypto represents the crypto structure.
 type Crypto struct {
 	parameters *Parameters
 	password   []byte
+
+	// mu guards the key derived for keySalt, kept since deriving it is
+	// deliberately expensive
+	mu      sync.Mutex
+	key     []byte
+	keySalt [16]byte
 }
 
 // Parameters holds the crypto parameters.
@@ -64,6 +74,13 @@ func (c *Crypto) SetParameters(parameters *Parameters) error {
 	return nil
 }
 
+// RenewNonce replaces the nonce with a random one, so that the derived key can
+// encrypt another content.
+func (c *Crypto) RenewNonce() error {
+	_, err := rand.Read(c.parameters.Nonce[:])
+	return err
+}
+
 // Parameters returns the crypto parameters.
 func (c *Crypto) Parameters() *Parameters {
 	return c.parameters
@@ -71,7 +88,7 @@ func (c *Crypto) Parameters() *Parameters {
 
 // Encode encrypts the given content using the stored parameters and password.
 func (c *Crypto) Encode(content, additionalData []byte) ([]byte, error) {
-	key := deriveKey(c.password, c.parameters.Salt[:])
+	key := c.derivedKey()
 
 	// Encrypt payload
 	aead, err := chacha20poly1305.NewX(key)
@@ -85,7 +102,7 @@ func (c *Crypto) Encode(content, additionalData []byte) ([]byte, error) {
 
 // Decode decrypts the given ciphertext using the stored parameters and password.
 func (c *Crypto) Decode(ciphertext, additionalData []byte) ([]byte, error) {
-	key := deriveKey(c.password, c.parameters.Salt[:])
+	key := c.derivedKey()
 
 	// Decrypt payload
 	aead, err := chacha20poly1305.NewX(key)
@@ -101,6 +118,20 @@ func (c *Crypto) Decode(ciphertext, additionalData []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+// derivedKey returns the key derived from the password and the current salt,
+// deriving it again only when the salt changed.
+func (c *Crypto) derivedKey() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.key == nil || c.keySalt != c.parameters.Salt {
+		c.key = deriveKey(c.password, c.parameters.Salt[:])
+		c.keySalt = c.parameters.Salt
+	}
+
+	return c.key
+}
+
 // deriveKey derives a key from the given password and salt using Argon2id.
 func deriveKey(password, salt []byte) []byte {
 	return argon2.IDKey(
@@ -413,3 +413,61 @@ func TestEncodeLargePayload(t *testing.T) {
 		t.Error("Decode failed for large payload")
 	}
 }
+
+func TestRenewNonce(t *testing.T) {
+	c := newCrypto(t)
+
+	plaintext := []byte("test message")
+	aad := []byte("aad")
+
+	ciphertext1, err := c.Encode(plaintext, aad)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	nonce := c.parameters.Nonce
+	if err := c.RenewNonce(); err != nil {
+		t.Fatalf("RenewNonce returned error: %v", err)
+	}
+
+	if c.parameters.Nonce == nonce {
+		t.Fatal("RenewNonce kept the nonce")
+	}
+
+	ciphertext2, err := c.Encode(plaintext, aad)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	if bytes.Equal(ciphertext1, ciphertext2) {
+		t.Error("Different nonces should produce different ciphertexts")
+	}
+
+	decoded, err := c.Decode(ciphertext2, aad)
+	if err != nil || !bytes.Equal(decoded, plaintext) {
+		t.Errorf("Decode = %s, %v, want %s", decoded, err, plaintext)
+	}
+}
+
+func TestEncodeFollowsSalt(t *testing.T) {
+	c := newCrypto(t)
+
+	plaintext := []byte("test message")
+	aad := []byte("aad")
+
+	ciphertext, err := c.Encode(plaintext, aad)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	// the key derived for the previous salt must not be reused
+	c.parameters.Salt[0] ^= 0xff
+	if _, err := c.Decode(ciphertext, aad); err == nil {
+		t.Fatal("Decode should fail once the salt changed")
+	}
+
+	c.parameters.Salt[0] ^= 0xff
+	if _, err := c.Decode(ciphertext, aad); err != nil {
+		t.Fatalf("Decode returned error: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+package ghost
+
+import "errors"
+
+// Ghost errors.
+var (
+	ErrCapacityExceeded = errors.New("ghost: data exceeds the capacity of the ghost mode")
+)
@@ -16,7 +16,7 @@ const maxQRBufferSize = 2953
 // EncodeToQR generates a QR code image containing the binary data and writes it to the provided io.Writer.
 func EncodeToQR(w io.Writer, data []byte) error {
 	if len(data) > maxQRBufferSize {
-		return fmt.Errorf("data size %d exceeds max QR code capacity of %d bytes", len(data), maxQRBufferSize)
+		return fmt.Errorf("%w: data size %d, max QR code capacity %d bytes", ErrCapacityExceeded, len(data), maxQRBufferSize)
 	}
 
 	// Encode binary data as base64
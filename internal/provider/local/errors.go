@@ -0,0 +1,25 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error definitions.
+var (
+	ErrDirMissing       = errors.New("local: directory option required")
+	ErrPayloadTooLarge  = errors.New("local: payload exceeds the maximum size")
+	ErrInvalidPath      = errors.New("local: path missing or invalid in meta")
+	ErrExpired          = errors.New("local: copy expired")
+	ErrSimulatedFailure = errors.New("local: simulated failure")
+	ErrWriteFailed      = errors.New("local: unable to write payload")
+	ErrReadFailed       = errors.New("local: unable to read payload")
+)
+
+func wrapWriteErr(err error) error {
+	return errors.Join(ErrWriteFailed, fmt.Errorf("write file: %w", err))
+}
+
+func wrapReadErr(err error) error {
+	return errors.Join(ErrReadFailed, fmt.Errorf("read file: %w", err))
+}
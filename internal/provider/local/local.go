@@ -0,0 +1,254 @@
+// Package local implements a provider storing the payloads as files in a
+// local directory, for offline use and for testing. It can simulate the
+// limits and the failures of remote providers.
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/provider"
+)
+
+// Name is the name the provider is registered with.
+const Name = "local"
+
+// Option keys of the local provider.
+const (
+	OptionDir                 = "dir"
+	OptionExpire              = "expire"
+	OptionUploadFailureRate   = "upload_failure_rate"
+	OptionDownloadFailureRate = "download_failure_rate"
+)
+
+const (
+	expire       = 0
+	maxSizeBytes = 100 * 1024 * 1024
+	dirPerm      = 0o700
+)
+
+var (
+	_ provider.Provider = (*Local)(nil)
+	_ provider.Deleter  = (*Local)(nil)
+)
+
+// Local implements the Provider interface over a directory.
+type Local struct {
+	dir                 string
+	maxSize             int64
+	expire              time.Duration
+	uploadFailureRate   float64
+	downloadFailureRate float64
+}
+
+// Meta holds the metadata for Local uploads.
+type Meta struct {
+	// Path is the path of the file relative to the directory.
+	Path string `json:"path"`
+}
+
+func init() {
+	provider.Register(provider.Registration{
+		Name:        Name,
+		Description: "local directory, for offline use and testing",
+		Capabilities: provider.Capabilities{
+			MaxSize: maxSizeBytes,
+			Expire:  expire,
+		},
+		Options: SupportedOptions,
+		Factory: func(options provider.Options) (provider.Provider, error) {
+			return New(options)
+		},
+	})
+}
+
+// SupportedOptions are the option keys accepted by New. The directory is
+// required, expire simulates the expiry of the copies and the failure rates,
+// between 0 and 1, the share of the requests failing.
+var SupportedOptions = []string{
+	OptionDir,
+	provider.OptionMaxSize,
+	OptionExpire,
+	OptionUploadFailureRate,
+	OptionDownloadFailureRate,
+}
+
+// New creates a new Local provider instance configured with options.
+func New(options provider.Options) (*Local, error) {
+	settings, err := provider.ParseOptions(Name, options, provider.Settings{
+		MaxSize: maxSizeBytes,
+	}, SupportedOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Local{
+		dir:     options[OptionDir],
+		maxSize: settings.MaxSize,
+		expire:  expire,
+	}
+
+	if l.dir == "" {
+		return nil, fmt.Errorf("%w: %s.%s", ErrDirMissing, Name, OptionDir)
+	}
+
+	if value, ok := options[OptionExpire]; ok {
+		l.expire, err = time.ParseDuration(value)
+		if err != nil || l.expire < 0 {
+			return nil, invalidOption(OptionExpire, value)
+		}
+	}
+
+	for key, rate := range map[string]*float64{
+		OptionUploadFailureRate:   &l.uploadFailureRate,
+		OptionDownloadFailureRate: &l.downloadFailureRate,
+	} {
+		value, ok := options[key]
+		if !ok {
+			continue
+		}
+
+		*rate, err = strconv.ParseFloat(value, 64)
+		if err != nil || *rate < 0 || *rate > 1 {
+			return nil, invalidOption(key, value)
+		}
+	}
+
+	if err := os.MkdirAll(l.dir, dirPerm); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func invalidOption(key, value string) error {
+	return fmt.Errorf("%w %s.%s=%q", provider.ErrInvalidOption, Name, key, value)
+}
+
+// Name returns the provider name.
+func (l *Local) Name() string {
+	return Name
+}
+
+// MaxSize returns the maximum allowed size for uploads.
+func (l *Local) MaxSize() int64 {
+	return l.maxSize
+}
+
+// Expire returns the simulated expiration duration for uploads.
+func (l *Local) Expire() time.Duration {
+	return l.expire
+}
+
+// Upload stores the payload in a randomly named file of the directory.
+func (l *Local) Upload(ctx context.Context, payload []byte) (content.Meta, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if fails(l.uploadFailureRate) {
+		return nil, fmt.Errorf("%w: upload", ErrSimulatedFailure)
+	}
+
+	if int64(len(payload)) > l.maxSize {
+		return nil, ErrPayloadTooLarge
+	}
+
+	path := rand.Text()
+
+	// written under a temporary name, so that no partial copy is ever read
+	tmp, err := os.CreateTemp(l.dir, ".upload-*")
+	if err != nil {
+		return nil, wrapWriteErr(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close()
+		return nil, wrapWriteErr(err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, wrapWriteErr(err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(l.dir, path)); err != nil {
+		return nil, wrapWriteErr(err)
+	}
+
+	return json.Marshal(Meta{Path: path})
+}
+
+// Download reads the file stored in Meta, unless it expired.
+func (l *Local) Download(ctx context.Context, meta content.Meta) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	path, err := l.path(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if fails(l.downloadFailureRate) {
+		return nil, fmt.Errorf("%w: download", ErrSimulatedFailure)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, wrapReadErr(err)
+	}
+
+	if l.expire > 0 && time.Since(info.ModTime()) > l.expire {
+		return nil, ErrExpired
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, wrapReadErr(err)
+	}
+
+	return data, nil
+}
+
+// Delete removes the file stored in Meta.
+func (l *Local) Delete(ctx context.Context, meta content.Meta) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := l.path(meta)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// path returns the path of the file stored in Meta, which must be inside the
+// directory.
+func (l *Local) path(meta content.Meta) (string, error) {
+	m := Meta{}
+	if err := json.Unmarshal(meta, &m); err != nil {
+		return "", err
+	}
+
+	if m.Path == "" || !filepath.IsLocal(m.Path) {
+		return "", ErrInvalidPath
+	}
+
+	return filepath.Join(l.dir, m.Path), nil
+}
+
+// fails reports whether a request fails at the given failure rate.
+func fails(rate float64) bool {
+	return rate > 0 && mathrand.Float64() < rate
+}
@@ -0,0 +1,123 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/henomis/umbra/internal/provider"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+
+	l, err := New(provider.Options{OptionDir: dir})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := context.Background()
+	payload := []byte{0, 1, 2, 255}
+
+	meta, err := l.Upload(ctx, payload)
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ReadDir() = %v, %v, want a single file", entries, err)
+	}
+
+	data, err := l.Download(ctx, meta)
+	if err != nil || !bytes.Equal(data, payload) {
+		t.Fatalf("Download() = %v, %v, want %v", data, err, payload)
+	}
+
+	if err := l.Delete(ctx, meta); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err := l.Download(ctx, meta); !errors.Is(err, ErrReadFailed) {
+		t.Fatalf("Download() of a deleted copy error = %v, want ErrReadFailed", err)
+	}
+
+	if _, err := l.Download(ctx, []byte(`{"path":"../outside"}`)); !errors.Is(err, ErrInvalidPath) {
+		t.Fatalf("Download() outside the directory error = %v, want ErrInvalidPath", err)
+	}
+}
+
+func TestLocalSimulation(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := New(provider.Options{
+		OptionDir:                 dir,
+		provider.OptionMaxSize:    "4",
+		OptionExpire:              "1h",
+		OptionDownloadFailureRate: "1",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if l.MaxSize() != 4 || l.Expire() != time.Hour {
+		t.Fatalf("MaxSize(), Expire() = %d, %s, want 4, 1h0m0s", l.MaxSize(), l.Expire())
+	}
+
+	ctx := context.Background()
+	if _, err := l.Upload(ctx, []byte("large")); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("Upload() error = %v, want ErrPayloadTooLarge", err)
+	}
+
+	meta, err := l.Upload(ctx, []byte("data"))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	if _, err := l.Download(ctx, meta); !errors.Is(err, ErrSimulatedFailure) {
+		t.Fatalf("Download() error = %v, want ErrSimulatedFailure", err)
+	}
+
+	l.downloadFailureRate = 0
+	entries, _ := os.ReadDir(dir)
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, entries[0].Name()), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.Download(ctx, meta); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Download() of an expired copy error = %v, want ErrExpired", err)
+	}
+
+	l.uploadFailureRate = 1
+	if _, err := l.Upload(ctx, []byte("data")); !errors.Is(err, ErrSimulatedFailure) {
+		t.Fatalf("Upload() error = %v, want ErrSimulatedFailure", err)
+	}
+}
+
+func TestLocalOptions(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		options provider.Options
+		want    error
+	}{
+		{"missing dir", provider.Options{}, ErrDirMissing},
+		{"expire", provider.Options{OptionDir: dir, OptionExpire: "soon"}, provider.ErrInvalidOption},
+		{"rate", provider.Options{OptionDir: dir, OptionUploadFailureRate: "1.5"}, provider.ErrInvalidOption},
+		{"unknown", provider.Options{OptionDir: dir, provider.OptionTimeout: "1s"}, provider.ErrUnknownOption},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.options); !errors.Is(err, tt.want) {
+				t.Fatalf("New() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
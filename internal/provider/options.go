@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Option keys shared by the built-in providers.
+const (
+	OptionBaseURL   = "base_url"
+	OptionEndpoint  = "endpoint"
+	OptionTimeout   = "timeout"
+	OptionUserAgent = "user_agent"
+	OptionMaxSize   = "max_size"
+)
+
+// Option errors.
+var (
+	ErrUnknownOption = errors.New("provider: unknown option")
+	ErrInvalidOption = errors.New("provider: invalid option value")
+)
+
+// Settings holds the values of the option keys shared by the built-in
+// providers.
+type Settings struct {
+	BaseURL   string
+	Endpoint  string
+	Timeout   time.Duration
+	UserAgent string
+	MaxSize   int64
+}
+
+// ParseOptions applies options to the provider defaults. Only the supported
+// keys are accepted, timeout is a duration such as 30s and max_size a
+// positive number of bytes.
+func ParseOptions(name string, options Options, defaults Settings, supported ...string) (Settings, error) {
+	settings := defaults
+
+	for key, value := range options {
+		if !slices.Contains(supported, key) {
+			return settings, fmt.Errorf("%w %q for %s, supported: %v", ErrUnknownOption, key, name, supported)
+		}
+
+		var err error
+		switch key {
+		case OptionBaseURL:
+			settings.BaseURL = value
+		case OptionEndpoint:
+			settings.Endpoint = value
+		case OptionUserAgent:
+			settings.UserAgent = value
+		case OptionTimeout:
+			settings.Timeout, err = time.ParseDuration(value)
+			if err == nil && settings.Timeout < 0 {
+				err = errors.New("negative timeout")
+			}
+		case OptionMaxSize:
+			settings.MaxSize, err = strconv.ParseInt(value, 10, 64)
+			if err == nil && settings.MaxSize <= 0 {
+				err = errors.New("max size must be positive")
+			}
+		}
+
+		if err != nil {
+			return settings, fmt.Errorf("%w %s.%s=%q: %w", ErrInvalidOption, name, key, value, err)
+		}
+	}
+
+	return settings, nil
+}
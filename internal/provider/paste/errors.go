@@ -0,0 +1,48 @@
+package paste
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error definitions.
+var (
+	ErrInvalidTransport = errors.New("paste: transport must be tcp or http")
+	ErrMissingEndpoint  = errors.New("paste: endpoint must not be empty")
+	ErrInvalidEncoding  = errors.New("paste: encoding must be base64 or raw")
+	ErrInvalidMaxSize   = errors.New("paste: max size must be positive")
+	ErrInvalidExpire    = errors.New("paste: expire must be a non negative duration")
+	ErrInvalidTimeout   = errors.New("paste: timeout must be a non negative duration")
+	ErrInvalidResponse  = errors.New("paste: only one of response regex and response JSON path can be set")
+	ErrInvalidRegex     = errors.New("paste: invalid response regex")
+	ErrConnectionFailed = errors.New("paste: connection failed")
+	ErrURLMissing       = errors.New("paste: no url found in response")
+	ErrPathNotFound     = errors.New("paste: JSON path not found")
+	ErrDecodeFailed     = errors.New("paste: unable to decode base64 content")
+)
+
+func wrapRegexErr(err error) error {
+	return errors.Join(ErrInvalidRegex, err)
+}
+
+func wrapConnectionErr(endpoint string, err error) error {
+	return errors.Join(ErrConnectionFailed, fmt.Errorf("exchange with %s: %w", endpoint, err))
+}
+
+// maxQuoted is the length of the response quoted in errors.
+const maxQuoted = 120
+
+func wrapNoURLErr(response string) error {
+	if len(response) > maxQuoted {
+		response = response[:maxQuoted] + "..."
+	}
+	return errors.Join(ErrURLMissing, fmt.Errorf("response %q", response))
+}
+
+func wrapJSONPathErr(path string, err error) error {
+	return errors.Join(ErrURLMissing, fmt.Errorf("look up %s: %w", path, err))
+}
+
+func wrapDecodeErr(err error) error {
+	return errors.Join(ErrDecodeFailed, fmt.Errorf("decode base64 payload: %w", err))
+}
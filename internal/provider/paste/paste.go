@@ -0,0 +1,512 @@
+// Package paste implements a generic paste service provider, driven by a
+// declarative Spec instead of code, for the services that take the payload
+// over a raw TCP connection or an HTTP request and answer with its URL.
+package paste
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/provider"
+)
+
+// Supported transports and encodings.
+const (
+	TCP    = "tcp"
+	HTTP   = "http"
+	Base64 = "base64"
+	Raw    = "raw"
+)
+
+const (
+	defaultTimeout     = 30 * time.Second
+	responseTrimCutset = "\x00\r\n "
+)
+
+// Spec declares how a paste service is used.
+type Spec struct {
+	// Transport is tcp, sending the payload over a raw connection like
+	// netcat, or http.
+	Transport string `json:"transport"`
+	// Endpoint is host:port for tcp, the URL the payload is sent to for http.
+	Endpoint string `json:"endpoint"`
+	// Method is the HTTP method, POST by default.
+	Method string `json:"method,omitempty"`
+	// FormField is the multipart form field holding the payload. The payload
+	// is the request body when empty.
+	FormField string `json:"form_field,omitempty"`
+	// Headers are sent with the upload requests only, as the download URL
+	// may point to another host.
+	Headers map[string]string `json:"headers,omitempty"`
+	// DownloadHeaders are sent with the download requests.
+	DownloadHeaders map[string]string `json:"download_headers,omitempty"`
+	// ResponseRegex extracts the paste URL from the response, from its url
+	// named group, its first group or the whole match.
+	ResponseRegex string `json:"response_regex,omitempty"`
+	// ResponseJSONPath extracts the paste URL from a JSON response, as dot
+	// separated keys and array indexes such as data.links.0.url.
+	ResponseJSONPath string `json:"response_json_path,omitempty"`
+	// DownloadURL is the template of the URL the payload is downloaded
+	// from. {url} is replaced by the paste URL and {name} by the named
+	// groups of ResponseRegex. The paste URL is used when empty.
+	DownloadURL string `json:"download_url,omitempty"`
+	// Encoding is base64, the default, or raw.
+	Encoding string `json:"encoding,omitempty"`
+	MaxSize  int64  `json:"max_size"`
+	// Expire is how long pastes are kept, such as 168h. Empty if they do not
+	// expire.
+	Expire string `json:"expire,omitempty"`
+	// Timeout is the default timeout of an upload or download, such as 15s.
+	// It is 30s when empty, and 0 disables it.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Validate checks that the spec is complete.
+func (s *Spec) Validate() error {
+	_, err := s.compile()
+	return err
+}
+
+// Capabilities returns the limits declared by the spec.
+func (s *Spec) Capabilities() provider.Capabilities {
+	expire, _ := time.ParseDuration(s.Expire)
+
+	encoding := Base64
+	if s.Encoding != "" {
+		encoding = s.Encoding
+	}
+
+	return provider.Capabilities{
+		MaxSize:  s.MaxSize,
+		Expire:   expire,
+		Encoding: encoding,
+	}
+}
+
+// Options returns the option keys accepted by the providers created from the
+// spec. HTTP services also accept base_url, replacing the endpoint like it.
+func (s *Spec) Options() []string {
+	if s.Transport == HTTP {
+		return slices.Insert(slices.Clone(SupportedOptions), 1, provider.OptionBaseURL)
+	}
+
+	return SupportedOptions
+}
+
+// compiled holds the parsed fields of a Spec.
+type compiled struct {
+	expire  time.Duration
+	timeout time.Duration
+	regex   *regexp.Regexp
+}
+
+func (s *Spec) compile() (*compiled, error) {
+	c := &compiled{timeout: defaultTimeout}
+
+	if s.Transport != TCP && s.Transport != HTTP {
+		return nil, ErrInvalidTransport
+	}
+
+	if s.Endpoint == "" {
+		return nil, ErrMissingEndpoint
+	}
+
+	if s.Encoding != "" && s.Encoding != Base64 && s.Encoding != Raw {
+		return nil, ErrInvalidEncoding
+	}
+
+	if s.MaxSize <= 0 {
+		return nil, ErrInvalidMaxSize
+	}
+
+	if s.ResponseRegex != "" && s.ResponseJSONPath != "" {
+		return nil, ErrInvalidResponse
+	}
+
+	if s.ResponseRegex != "" {
+		regex, err := regexp.Compile(s.ResponseRegex)
+		if err != nil {
+			return nil, wrapRegexErr(err)
+		}
+		c.regex = regex
+	}
+
+	if s.Expire != "" {
+		expire, err := time.ParseDuration(s.Expire)
+		if err != nil || expire < 0 {
+			return nil, ErrInvalidExpire
+		}
+		c.expire = expire
+	}
+
+	if s.Timeout != "" {
+		timeout, err := time.ParseDuration(s.Timeout)
+		if err != nil || timeout < 0 {
+			return nil, ErrInvalidTimeout
+		}
+		c.timeout = timeout
+	}
+
+	return c, nil
+}
+
+// Register makes the paste service declared by spec available as a built-in
+// provider used by default. It is meant to be called from the init function
+// of the provider packages and panics if the spec is invalid.
+func Register(name, description string, spec Spec) {
+	if err := spec.Validate(); err != nil {
+		panic(fmt.Sprintf("paste: %s: %v", name, err))
+	}
+
+	provider.Register(provider.Registration{
+		Name:         name,
+		Description:  description,
+		Capabilities: spec.Capabilities(),
+		Options:      spec.Options(),
+		Default:      true,
+		Factory: func(options provider.Options) (provider.Provider, error) {
+			return New(name, spec, options)
+		},
+	})
+}
+
+// Paste implements provider.Provider for a paste service declared by a Spec.
+type Paste struct {
+	name     string
+	spec     Spec
+	compiled *compiled
+	endpoint string
+	client   *http.Client
+	timeout  time.Duration
+	agent    string
+	maxSize  int64
+}
+
+// Meta holds the metadata for paste uploads.
+type Meta struct {
+	URL string `json:"url"`
+}
+
+var _ provider.Provider = (*Paste)(nil)
+
+// SupportedOptions are the option keys accepted by New. The endpoint replaces
+// the one of the spec.
+var SupportedOptions = []string{
+	provider.OptionEndpoint,
+	provider.OptionTimeout,
+	provider.OptionUserAgent,
+	provider.OptionMaxSize,
+}
+
+// New creates a provider named name for the paste service declared by spec,
+// configured with options.
+func New(name string, spec Spec, options provider.Options) (*Paste, error) {
+	c, err := spec.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := provider.ParseOptions(name, options, provider.Settings{
+		Endpoint: spec.Endpoint,
+		Timeout:  c.timeout,
+		MaxSize:  spec.MaxSize,
+	}, spec.Options()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Paste{
+		name:     name,
+		spec:     spec,
+		compiled: c,
+		endpoint: cmp.Or(settings.BaseURL, settings.Endpoint),
+		client: &http.Client{
+			Timeout: settings.Timeout,
+		},
+		timeout: settings.Timeout,
+		agent:   settings.UserAgent,
+		maxSize: settings.MaxSize,
+	}, nil
+}
+
+// Name returns the provider name.
+func (p *Paste) Name() string {
+	return p.name
+}
+
+// MaxSize returns the maximum allowed size for uploads.
+func (p *Paste) MaxSize() int64 {
+	return p.maxSize
+}
+
+// EncodedSize returns the size of a payload of n bytes once encoded.
+func (p *Paste) EncodedSize(n int64) int64 {
+	if p.spec.Encoding == Raw {
+		return n
+	}
+	return int64(base64.StdEncoding.EncodedLen(int(n)))
+}
+
+// Expire returns the expiration duration for uploads.
+func (p *Paste) Expire() time.Duration {
+	return p.compiled.expire
+}
+
+// Upload sends the payload to the paste service.
+func (p *Paste) Upload(ctx context.Context, payload []byte) (content.Meta, error) {
+	body := payload
+	if p.spec.Encoding != Raw {
+		body = []byte(base64.StdEncoding.EncodeToString(payload))
+	}
+
+	var response []byte
+	var err error
+	if p.spec.Transport == TCP {
+		response, err = p.sendTCP(ctx, body)
+	} else {
+		response, err = p.sendHTTP(ctx, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := p.downloadURL(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Meta{URL: url})
+}
+
+// sendTCP writes body to a raw connection, closing its write side like
+// netcat, and returns what the service answers.
+func (p *Paste) sendTCP(ctx context.Context, body []byte) ([]byte, error) {
+	dialer := &net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.DialContext(ctx, TCP, p.endpoint)
+	if err != nil {
+		return nil, wrapConnectionErr(p.endpoint, err)
+	}
+	defer conn.Close()
+
+	if p.timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+			return nil, wrapConnectionErr(p.endpoint, err)
+		}
+	}
+
+	if _, err := conn.Write(body); err != nil {
+		return nil, wrapConnectionErr(p.endpoint, err)
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.CloseWrite(); err != nil {
+			return nil, wrapConnectionErr(p.endpoint, err)
+		}
+	}
+
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, wrapConnectionErr(p.endpoint, err)
+	}
+
+	return response, nil
+}
+
+// sendHTTP sends body as the request body or as a multipart form field and
+// returns the response body.
+func (p *Paste) sendHTTP(ctx context.Context, body []byte) ([]byte, error) {
+	method := p.spec.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	contentType := "text/plain"
+	if p.spec.FormField != "" {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+
+		part, err := writer.CreateFormField(p.spec.FormField)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := part.Write(body); err != nil {
+			return nil, err
+		}
+
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+
+		body = buf.Bytes()
+		contentType = writer.FormDataContentType()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	p.setHeaders(req, p.spec.Headers)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := provider.CheckResponse(p.name, resp); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (p *Paste) setHeaders(req *http.Request, headers map[string]string) {
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	if p.agent != "" {
+		req.Header.Set("User-Agent", p.agent)
+	}
+}
+
+// downloadURL extracts the paste URL from the response and expands the
+// download URL template with it.
+func (p *Paste) downloadURL(response []byte) (string, error) {
+	text := strings.TrimSpace(string(bytes.TrimRight(response, responseTrimCutset)))
+
+	url := text
+	groups := make(map[string]string)
+
+	switch {
+	case p.spec.ResponseJSONPath != "":
+		value, err := lookupJSONPath(response, p.spec.ResponseJSONPath)
+		if err != nil {
+			return "", err
+		}
+		url = value
+	case p.compiled.regex != nil:
+		match := p.compiled.regex.FindStringSubmatch(text)
+		if match == nil {
+			return "", wrapNoURLErr(text)
+		}
+
+		url = match[0]
+		if len(match) > 1 {
+			url = match[1]
+		}
+
+		for i, name := range p.compiled.regex.SubexpNames() {
+			if name != "" {
+				groups[name] = match[i]
+			}
+		}
+		if named, ok := groups["url"]; ok {
+			url = named
+		}
+	}
+
+	if url == "" {
+		return "", wrapNoURLErr(text)
+	}
+
+	if p.spec.DownloadURL == "" {
+		return url, nil
+	}
+
+	replacements := []string{"{url}", url}
+	for name, value := range groups {
+		replacements = append(replacements, "{"+name+"}", value)
+	}
+
+	return strings.NewReplacer(replacements...).Replace(p.spec.DownloadURL), nil
+}
+
+// lookupJSONPath returns the string found at the dot separated path of the
+// JSON document.
+func lookupJSONPath(data []byte, path string) (string, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", wrapJSONPathErr(path, err)
+	}
+
+	for key := range strings.SplitSeq(path, ".") {
+		switch node := value.(type) {
+		case map[string]any:
+			value = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", wrapJSONPathErr(path, ErrPathNotFound)
+			}
+			value = node[i]
+		default:
+			return "", wrapJSONPathErr(path, ErrPathNotFound)
+		}
+	}
+
+	url, ok := value.(string)
+	if !ok {
+		return "", wrapJSONPathErr(path, ErrPathNotFound)
+	}
+
+	return url, nil
+}
+
+// Download fetches the data from the URL stored in Meta.
+func (p *Paste) Download(ctx context.Context, meta content.Meta) ([]byte, error) {
+	m := Meta{}
+	if err := json.Unmarshal(meta, &m); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.URL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	p.setHeaders(req, p.spec.DownloadHeaders)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := provider.CheckResponse(p.name, resp); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.spec.Encoding == Raw {
+		return body, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, wrapDecodeErr(err)
+	}
+
+	return data, nil
+}
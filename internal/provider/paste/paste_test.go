@@ -0,0 +1,272 @@
+package paste
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/provider"
+)
+
+// pasteServer stores pastes in memory, serving them under /raw/<id>. It
+// records the headers of the last download.
+type pasteServer struct {
+	mu       sync.Mutex
+	pastes   []string
+	download http.Header
+}
+
+func (s *pasteServer) store(data string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pastes = append(s.pastes, data)
+	return len(s.pastes) - 1
+}
+
+func (s *pasteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.download = r.Header.Clone()
+
+		var id int
+		if _, err := fmt.Sscanf(r.URL.Path, "/raw/%d", &id); err != nil || id >= len(s.pastes) {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, s.pastes[id])
+		return
+	}
+
+	if r.Header.Get("X-Token") != "secret" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	id := s.store(r.FormValue("content"))
+	fmt.Fprintf(w, `{"status":"ok","data":{"links":[{"raw":"/raw/%d"}]}}`, id)
+}
+
+func roundTrip(t *testing.T, p *Paste) {
+	t.Helper()
+
+	ctx := context.Background()
+	payload := []byte{0, 1, 2, 255, '\n'}
+
+	meta, err := p.Upload(ctx, payload)
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	data, err := p.Download(ctx, meta)
+	if err != nil || !bytes.Equal(data, payload) {
+		t.Fatalf("Download() = %v, %v, want %v", data, err, payload)
+	}
+}
+
+func TestPasteHTTP(t *testing.T) {
+	web := &pasteServer{}
+	server := httptest.NewServer(web)
+	defer server.Close()
+
+	p, err := New("site", Spec{
+		Transport:        HTTP,
+		Endpoint:         server.URL,
+		FormField:        "content",
+		Headers:          map[string]string{"X-Token": "secret"},
+		DownloadHeaders:  map[string]string{"X-Download": "yes"},
+		ResponseJSONPath: "data.links.0.raw",
+		DownloadURL:      server.URL + "{url}",
+		MaxSize:          1024,
+		Expire:           "24h",
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if p.Name() != "site" || p.MaxSize() != 1024 || p.Expire().Hours() != 24 {
+		t.Fatalf("Name(), MaxSize(), Expire() = %s, %d, %s", p.Name(), p.MaxSize(), p.Expire())
+	}
+
+	roundTrip(t, p)
+
+	// the upload headers are not sent to the download URL
+	if web.download.Get("X-Token") != "" || web.download.Get("X-Download") != "yes" {
+		t.Fatalf("download headers = %v, want X-Download only", web.download)
+	}
+}
+
+func TestPasteDefangedURL(t *testing.T) {
+	web := &pasteServer{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			web.ServeHTTP(w, r)
+			return
+		}
+		fmt.Fprintf(w, "hxxps://%s/raw/%d\n", r.Host, web.store(r.FormValue("content")))
+	}))
+	defer server.Close()
+
+	// answers like clbin, with the endpoint given as base_url
+	p, err := New("site", Spec{
+		Transport:     HTTP,
+		Endpoint:      "https://paste.invalid",
+		FormField:     "content",
+		ResponseRegex: `h(?:xx|tt)ps://(?P<path>\S+)`,
+		DownloadURL:   "https://{path}",
+		MaxSize:       1024,
+	}, map[string]string{"base_url": server.URL})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	p.client.Transport = server.Client().Transport
+
+	roundTrip(t, p)
+}
+
+func TestPasteTCP(t *testing.T) {
+	web := &pasteServer{}
+	server := httptest.NewServer(web)
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// answers like termbin once the payload is fully sent
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			data, _ := io.ReadAll(conn)
+			fmt.Fprintf(conn, "paste stored: %s/p/%d\n\x00", server.URL, web.store(string(data)))
+			conn.Close()
+		}
+	}()
+
+	p, err := New("nc", Spec{
+		Transport:     TCP,
+		Endpoint:      listener.Addr().String(),
+		ResponseRegex: `(?P<url>http://\S+)/p/(?P<id>\d+)`,
+		DownloadURL:   "{url}/raw/{id}",
+		MaxSize:       1024,
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	roundTrip(t, p)
+
+	if _, err := New("nc", Spec{Transport: TCP, Endpoint: "x", MaxSize: 1}, map[string]string{"base_url": "x"}); err == nil {
+		t.Fatal("New() accepted an unsupported option")
+	}
+
+	// a zero timeout leaves the exchanges without deadline
+	p, err = New("nc", Spec{Transport: TCP, Endpoint: "x", MaxSize: 1, Timeout: "0"}, nil)
+	if err != nil || p.timeout != 0 || p.client.Timeout != 0 {
+		t.Fatalf("New() = %v, %v, want no timeout", p, err)
+	}
+}
+
+// registerRuns numbers the runs of TestRegister, which registers a provider
+// under a new name each time since the registry cannot be emptied.
+var registerRuns int
+
+func TestRegister(t *testing.T) {
+	web := &pasteServer{}
+	server := httptest.NewServer(web)
+	defer server.Close()
+
+	registerRuns++
+	name := fmt.Sprintf("test-paste-%d", registerRuns)
+
+	spec := Spec{Transport: TCP, Endpoint: "paste.example:9999", MaxSize: 1024, Expire: "168h"}
+	Register(name, "test paste service", spec)
+
+	r, ok := provider.Lookup(name)
+	if !ok || !r.Default || r.Capabilities != spec.Capabilities() {
+		t.Fatalf("Lookup() = %+v, %v, want the default registration of the spec", r, ok)
+	}
+
+	p, err := r.Factory(nil)
+	if err != nil {
+		t.Fatalf("Factory() error = %v", err)
+	}
+
+	// copies are identified by their url, as the hand-written providers did
+	meta := content.Meta(fmt.Sprintf(`{"url":"%s/raw/%d"}`, server.URL, web.store("AAEC/w==")))
+	data, err := p.Download(context.Background(), meta)
+	if err != nil || !bytes.Equal(data, []byte{0, 1, 2, 255}) {
+		t.Fatalf("Download() = %v, %v, want [0 1 2 255]", data, err)
+	}
+}
+
+func TestPasteNoURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "rate limited")
+	}))
+	defer server.Close()
+
+	p, err := New("site", Spec{
+		Transport:     HTTP,
+		Endpoint:      server.URL,
+		ResponseRegex: `https?://\S+`,
+		MaxSize:       1024,
+	}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := p.Upload(context.Background(), []byte("x")); !errors.Is(err, ErrURLMissing) {
+		t.Fatalf("Upload() error = %v, want ErrURLMissing", err)
+	}
+}
+
+func TestSpecValidate(t *testing.T) {
+	valid := Spec{Transport: HTTP, Endpoint: "https://paste.example", MaxSize: 1}
+
+	tests := []struct {
+		name   string
+		modify func(*Spec)
+		want   error
+	}{
+		{"valid", func(*Spec) {}, nil},
+		{"transport", func(s *Spec) { s.Transport = "udp" }, ErrInvalidTransport},
+		{"endpoint", func(s *Spec) { s.Endpoint = "" }, ErrMissingEndpoint},
+		{"encoding", func(s *Spec) { s.Encoding = "hex" }, ErrInvalidEncoding},
+		{"max size", func(s *Spec) { s.MaxSize = 0 }, ErrInvalidMaxSize},
+		{"expire", func(s *Spec) { s.Expire = "1 week" }, ErrInvalidExpire},
+		{"timeout", func(s *Spec) { s.Timeout = "-1s" }, ErrInvalidTimeout},
+		{"regex", func(s *Spec) { s.ResponseRegex = "(" }, ErrInvalidRegex},
+		{"response", func(s *Spec) { s.ResponseRegex, s.ResponseJSONPath = ".", "url" }, ErrInvalidResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := valid
+			tt.modify(&spec)
+
+			err := spec.Validate()
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if c := valid.Capabilities(); c.Encoding != Base64 || c.MaxSize != 1 || c.Expire != 0 {
+		t.Fatalf("Capabilities() = %+v", c)
+	}
+}
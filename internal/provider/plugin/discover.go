@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/henomis/umbra/internal/provider"
+)
+
+// Prefix is the prefix of the plugin executable names.
+const Prefix = "umbra-provider-"
+
+var discoverOnce sync.Once
+
+// Discover registers the plugins found in the PATH directories. The first
+// executable found for a name is used, as the shell does, and plugins named
+// after a registered provider are ignored. Plugins are not used by default.
+func Discover() {
+	discoverOnce.Do(func() {
+		for name, path := range find(filepath.SplitList(os.Getenv("PATH"))) {
+			if _, ok := provider.Lookup(name); ok {
+				continue
+			}
+
+			provider.Register(provider.Registration{
+				Name:        name,
+				Description: "external plugin " + path,
+				Factory: func(options provider.Options) (provider.Provider, error) {
+					return New(name, path, options)
+				},
+			})
+		}
+	})
+}
+
+// find returns the plugin executables found in dirs by name.
+func find(dirs []string) map[string]string {
+	plugins := make(map[string]string)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok {
+				continue
+			}
+
+			if _, found := plugins[name]; found {
+				continue
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			if isExecutable(path) {
+				plugins[name] = path
+			}
+		}
+	}
+
+	return plugins
+}
+
+// pluginName returns the provider name of a plugin executable file name.
+// Names holding characters used by the provider options, the provider lists
+// or the manifest paths are rejected.
+func pluginName(file string) (string, bool) {
+	if runtime.GOOS == "windows" {
+		file = strings.TrimSuffix(file, filepath.Ext(file))
+	}
+
+	name, ok := strings.CutPrefix(file, Prefix)
+	if !ok || name == "" || strings.ContainsAny(name, ".:,=") {
+		return "", false
+	}
+
+	return name, true
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0o111 != 0
+}
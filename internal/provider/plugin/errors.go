@@ -0,0 +1,28 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error definitions.
+var (
+	ErrStartFailed  = errors.New("plugin: unable to start")
+	ErrPluginExited = errors.New("plugin: exited")
+	ErrProtocol     = errors.New("plugin: invalid response")
+	ErrWriteFailed  = errors.New("plugin: unable to send request")
+	ErrNameMismatch = errors.New("plugin: name mismatch")
+	ErrMetaMissing  = errors.New("plugin: meta missing in upload response")
+)
+
+func wrapStartErr(name string, err error) error {
+	return errors.Join(ErrStartFailed, fmt.Errorf("start %s: %w", name, err))
+}
+
+func wrapProtocolErr(name string, err error) error {
+	return errors.Join(ErrProtocol, fmt.Errorf("decode %s response: %w", name, err))
+}
+
+func wrapWriteErr(name string, err error) error {
+	return errors.Join(ErrWriteFailed, fmt.Errorf("write %s request: %w", name, err))
+}
@@ -0,0 +1,381 @@
+// Package plugin runs external providers, executables named
+// umbra-provider-<name> found in the PATH, speaking a line-delimited JSON
+// protocol over their standard input and output.
+//
+// Every request is a single line holding a JSON object with an "id" and a
+// "method", answered by a single line holding a JSON object with the same
+// "id". Requests may be sent before the previous ones are answered, and
+// responses may come in any order. Binary data is base64 encoded, as JSON
+// encodes byte slices. A response with a non empty "error" reports a failure.
+//
+//	{"id":1,"method":"describe","options":{"key":"value"}}
+//	{"id":1,"name":"store","max_size":1048576,"expire":604800,"delete":true}
+//
+//	{"id":2,"method":"upload","data":"<base64 payload>"}
+//	{"id":2,"meta":{"key":"opaque JSON identifying the copy"}}
+//
+//	{"id":3,"method":"download","meta":{"key":"opaque JSON identifying the copy"}}
+//	{"id":3,"data":"<base64 payload>"}
+//
+//	{"id":4,"method":"delete","meta":{"key":"opaque JSON identifying the copy"}}
+//	{"id":4}
+//
+// Describe is the first request, carrying the provider options, and must be
+// answered within a few seconds. Its response gives the maximum payload size
+// in bytes, which must be positive, the expiry of the copies in
+// seconds, zero if they do not expire, and whether delete is supported. The
+// standard error of the plugin is passed through, and the plugin must exit
+// once its standard input is closed. A plugin that stops reading its input is
+// killed when the context of a request being written to it is done.
+package plugin
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/provider"
+)
+
+var (
+	// describeTimeout bounds the wait for the describe response.
+	describeTimeout = 10 * time.Second
+	// closeTimeout is how long a closed plugin may take to exit before it is
+	// killed.
+	closeTimeout = 5 * time.Second
+)
+
+// Protocol methods.
+const (
+	methodDescribe = "describe"
+	methodUpload   = "upload"
+	methodDownload = "download"
+	methodDelete   = "delete"
+)
+
+type request struct {
+	ID      uint64            `json:"id"`
+	Method  string            `json:"method"`
+	Options map[string]string `json:"options,omitempty"`
+	Data    []byte            `json:"data,omitempty"`
+	Meta    content.Meta      `json:"meta,omitempty"`
+}
+
+type response struct {
+	ID     uint64       `json:"id"`
+	Error  string       `json:"error,omitempty"`
+	Data   []byte       `json:"data,omitempty"`
+	Meta   content.Meta `json:"meta,omitempty"`
+	Name   string       `json:"name,omitempty"`
+	Size   int64        `json:"max_size,omitempty"`
+	Expire int64        `json:"expire,omitempty"`
+	Delete bool         `json:"delete,omitempty"`
+}
+
+// Plugin is a provider backed by a running plugin process.
+type Plugin struct {
+	name    string
+	maxSize int64
+	expire  time.Duration
+	delete  bool
+
+	stdin     io.WriteCloser
+	writeMu   sync.Mutex
+	cancel    context.CancelFunc
+	exited    chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan response
+	exitErr error
+}
+
+var (
+	_ provider.Provider = (*Plugin)(nil)
+	_ provider.Deleter  = (*Plugin)(nil)
+)
+
+// New starts the plugin executable at path and describes it with options.
+// The process runs until Close is called or umbra exits.
+func New(name, path string, options provider.Options) (*Plugin, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, wrapStartErr(name, err)
+	}
+
+	p := &Plugin{
+		name:    name,
+		stdin:   stdin,
+		cancel:  cancel,
+		exited:  make(chan struct{}),
+		pending: make(map[uint64]chan response),
+	}
+
+	go p.read(cmd, stdout)
+
+	describeCtx, describeCancel := context.WithTimeout(ctx, describeTimeout)
+	defer describeCancel()
+
+	resp, err := p.call(describeCtx, request{Method: methodDescribe, Options: options})
+	if err != nil {
+		p.kill()
+		return nil, err
+	}
+
+	if resp.Name != "" && resp.Name != name {
+		p.kill()
+		return nil, fmt.Errorf("%w: %s describes itself as %s", ErrNameMismatch, name, resp.Name)
+	}
+
+	if resp.Size <= 0 {
+		p.kill()
+		return nil, fmt.Errorf("%w: %s describes max_size %d", ErrProtocol, name, resp.Size)
+	}
+
+	p.maxSize = resp.Size
+	p.expire = time.Duration(resp.Expire) * time.Second
+	p.delete = resp.Delete
+
+	return p, nil
+}
+
+// read dispatches the responses of the plugin until its output is closed,
+// then fails the pending requests.
+func (p *Plugin) read(cmd *exec.Cmd, stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+
+	var readErr error
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var resp response
+			if err := json.Unmarshal(line, &resp); err != nil {
+				readErr = wrapProtocolErr(p.name, err)
+				break
+			}
+			p.dispatch(resp)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// stop a misbehaving plugin, which is not answering anymore
+	if readErr != nil {
+		_ = cmd.Process.Kill()
+	}
+
+	waitErr := cmd.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.exitErr = fmt.Errorf("%w: %s", ErrPluginExited, p.name)
+	if err := cmp.Or(readErr, waitErr); err != nil {
+		p.exitErr = fmt.Errorf("%w: %s: %w", ErrPluginExited, p.name, err)
+	}
+
+	for id, ch := range p.pending {
+		delete(p.pending, id)
+		close(ch)
+	}
+
+	close(p.exited)
+}
+
+func (p *Plugin) dispatch(resp response) {
+	p.mu.Lock()
+	ch, ok := p.pending[resp.ID]
+	delete(p.pending, resp.ID)
+	p.mu.Unlock()
+
+	if ok {
+		ch <- resp
+	}
+}
+
+// call sends req and waits for its response.
+func (p *Plugin) call(ctx context.Context, req request) (response, error) {
+	ch := make(chan response, 1)
+
+	p.mu.Lock()
+	if p.exitErr != nil {
+		p.mu.Unlock()
+		return response{}, p.exitErr
+	}
+	p.nextID++
+	req.ID = p.nextID
+	p.pending[req.ID] = ch
+	p.mu.Unlock()
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		p.forget(req.ID)
+		return response{}, err
+	}
+
+	// the write blocks while the plugin is not reading its input, so it
+	// runs aside and the request is given up when ctx is done
+	writing := make(chan struct{})
+	written := make(chan error, 1)
+	go func() {
+		p.writeMu.Lock()
+		defer p.writeMu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			written <- err
+			return
+		}
+		close(writing)
+
+		_, err := p.stdin.Write(append(line, '\n'))
+		written <- err
+	}()
+
+	select {
+	case err = <-written:
+	case <-ctx.Done():
+		p.forget(req.ID)
+		select {
+		case <-writing:
+			// a request written in part would corrupt the following ones,
+			// so the plugin is stopped, which also ends the write
+			select {
+			case <-written:
+			default:
+				p.kill()
+			}
+		default:
+		}
+		return response{}, ctx.Err()
+	}
+	if err != nil {
+		p.forget(req.ID)
+		return response{}, wrapWriteErr(p.name, err)
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			return response{}, p.exitErr
+		}
+		if resp.Error != "" {
+			return response{}, fmt.Errorf("%s: %s", p.name, resp.Error)
+		}
+		return resp, nil
+	case <-ctx.Done():
+		p.forget(req.ID)
+		return response{}, ctx.Err()
+	}
+}
+
+func (p *Plugin) forget(id uint64) {
+	p.mu.Lock()
+	delete(p.pending, id)
+	p.mu.Unlock()
+}
+
+// Close closes the standard input of the plugin and waits for it to exit,
+// killing it if it does not exit in time. Later calls return the same result.
+func (p *Plugin) Close() error {
+	p.closeOnce.Do(func() {
+		p.closeErr = p.stdin.Close()
+
+		select {
+		case <-p.exited:
+		case <-time.After(closeTimeout):
+		}
+		p.kill()
+	})
+
+	return p.closeErr
+}
+
+// kill stops the plugin process, if still running, and waits for it to exit.
+func (p *Plugin) kill() {
+	p.cancel()
+	<-p.exited
+}
+
+// Name returns the provider name.
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// MaxSize returns the maximum payload size reported by the plugin.
+func (p *Plugin) MaxSize() int64 {
+	return p.maxSize
+}
+
+// Expire returns the expiry of the copies reported by the plugin.
+func (p *Plugin) Expire() time.Duration {
+	return p.expire
+}
+
+// Upload sends the payload to the plugin.
+func (p *Plugin) Upload(ctx context.Context, payload []byte) (content.Meta, error) {
+	resp, err := p.call(ctx, request{Method: methodUpload, Data: payload})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Meta) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMetaMissing, p.name)
+	}
+
+	return resp.Meta, nil
+}
+
+// Download fetches the payload identified by meta from the plugin.
+func (p *Plugin) Download(ctx context.Context, meta content.Meta) ([]byte, error) {
+	resp, err := p.call(ctx, request{Method: methodDownload, Meta: meta})
+	if err != nil {
+		return nil, err
+	}
+
+	// stored payloads are never empty, as encrypted chunks carry an overhead
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("%w: %s: data missing in download response", ErrProtocol, p.name)
+	}
+
+	return resp.Data, nil
+}
+
+// Delete removes the payload identified by meta, if the plugin supports it.
+func (p *Plugin) Delete(ctx context.Context, meta content.Meta) error {
+	if !p.delete {
+		return fmt.Errorf("%w: %s", provider.ErrDeleteUnsupported, p.name)
+	}
+
+	_, err := p.call(ctx, request{Method: methodDelete, Meta: meta})
+	return err
+}
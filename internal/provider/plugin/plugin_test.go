@@ -0,0 +1,305 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The test binary acts as a plugin when started with helperEnv set.
+const helperEnv = "UMBRA_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) == "1" {
+		runHelperPlugin()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// runHelperPlugin stores payloads in memory, answering the requests
+// concurrently and so possibly out of order.
+func runHelperPlugin() {
+	var mu sync.Mutex
+	stored := make(map[string][]byte)
+	var options map[string]string
+
+	encoder := json.NewEncoder(os.Stdout)
+	reply := func(resp map[string]any) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = encoder.Encode(resp)
+	}
+
+	var wg sync.WaitGroup
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			os.Exit(2)
+		}
+
+		if req.Method == methodDescribe {
+			options = req.Options
+			if options["describe"] == "hang" {
+				continue
+			}
+
+			maxSize := 1024
+			if size, ok := options["max_size"]; ok {
+				maxSize, _ = strconv.Atoi(size)
+			}
+			reply(map[string]any{"id": req.ID, "name": "helper", "max_size": maxSize, "expire": 60, "delete": true})
+			if options["read"] == "stop" {
+				time.Sleep(time.Hour)
+			}
+			continue
+		}
+
+		if req.Method == methodUpload && options["crash"] == "upload" {
+			os.Exit(3)
+		}
+
+		wg.Go(func() {
+			mu.Lock()
+			var key struct{ Key string }
+			_ = json.Unmarshal(req.Meta, &key)
+			data, found := stored[key.Key]
+			resp := map[string]any{"id": req.ID}
+			switch {
+			case req.Method == methodUpload:
+				key.Key = fmt.Sprint(len(stored))
+				stored[key.Key] = req.Data
+				resp["meta"] = key
+			case !found:
+				resp["error"] = "not found"
+			case req.Method == methodDownload && options["empty"] == "download":
+			case req.Method == methodDownload:
+				resp["data"] = data
+			case req.Method == methodDelete:
+				delete(stored, key.Key)
+			}
+			mu.Unlock()
+
+			reply(resp)
+		})
+	}
+
+	wg.Wait()
+}
+
+func startHelper(t *testing.T, name string, options map[string]string) (*Plugin, error) {
+	t.Helper()
+	t.Setenv(helperEnv, "1")
+
+	p, err := New(name, os.Args[0], options)
+	if err == nil {
+		t.Cleanup(func() { _ = p.Close() })
+	}
+	return p, err
+}
+
+func TestPluginRoundTrip(t *testing.T) {
+	p, err := startHelper(t, "helper", nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if p.MaxSize() != 1024 || p.Expire() != time.Minute {
+		t.Fatalf("MaxSize(), Expire() = %d, %s, want 1024, 1m0s", p.MaxSize(), p.Expire())
+	}
+
+	ctx := context.Background()
+	payload := []byte{0, 1, 2, 255}
+
+	meta, err := p.Upload(ctx, payload)
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	data, err := p.Download(ctx, meta)
+	if err != nil || !bytes.Equal(data, payload) {
+		t.Fatalf("Download() = %v, %v, want %v", data, err, payload)
+	}
+
+	if err := p.Delete(ctx, meta); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err := p.Download(ctx, meta); err == nil {
+		t.Fatal("Download() of a deleted payload succeeded")
+	}
+}
+
+func TestPluginConcurrentRequests(t *testing.T) {
+	p, err := startHelper(t, "helper", nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			payload := []byte{byte(i)}
+
+			meta, err := p.Upload(context.Background(), payload)
+			if err != nil {
+				t.Errorf("Upload() error = %v", err)
+				return
+			}
+
+			data, err := p.Download(context.Background(), meta)
+			if err != nil || !bytes.Equal(data, payload) {
+				t.Errorf("Download() = %v, %v, want %v", data, err, payload)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestPluginNameMismatch(t *testing.T) {
+	if _, err := startHelper(t, "other", nil); !errors.Is(err, ErrNameMismatch) {
+		t.Fatalf("New() error = %v, want ErrNameMismatch", err)
+	}
+}
+
+func TestPluginExited(t *testing.T) {
+	p, err := startHelper(t, "helper", map[string]string{"crash": "upload"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := p.Upload(context.Background(), []byte("x")); !errors.Is(err, ErrPluginExited) {
+		t.Fatalf("Upload() error = %v, want ErrPluginExited", err)
+	}
+
+	if _, err := p.Download(context.Background(), []byte(`{}`)); !errors.Is(err, ErrPluginExited) {
+		t.Fatalf("Download() error = %v, want ErrPluginExited", err)
+	}
+}
+
+func TestPluginStalledInput(t *testing.T) {
+	p, err := startHelper(t, "helper", map[string]string{"read": "stop", "max_size": "1048576"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// the payload exceeds the pipe buffer, so the write blocks
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := p.Upload(ctx, make([]byte, 1<<20)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Upload() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	if _, err := p.Download(context.Background(), []byte(`{}`)); !errors.Is(err, ErrPluginExited) {
+		t.Fatalf("Download() error = %v, want ErrPluginExited", err)
+	}
+}
+
+func TestPluginDescribe(t *testing.T) {
+	timeout := describeTimeout
+	describeTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { describeTimeout = timeout })
+
+	tests := []struct {
+		name    string
+		options map[string]string
+		want    error
+	}{
+		{"unanswered", map[string]string{"describe": "hang"}, context.DeadlineExceeded},
+		{"zero max size", map[string]string{"max_size": "0"}, ErrProtocol},
+		{"negative max size", map[string]string{"max_size": "-1"}, ErrProtocol},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := startHelper(t, "helper", tt.options); !errors.Is(err, tt.want) {
+				t.Fatalf("New() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPluginEmptyDownload(t *testing.T) {
+	p, err := startHelper(t, "helper", map[string]string{"empty": "download"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	meta, err := p.Upload(context.Background(), []byte("x"))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	if _, err := p.Download(context.Background(), meta); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("Download() error = %v, want ErrProtocol", err)
+	}
+}
+
+func TestPluginClose(t *testing.T) {
+	p, err := startHelper(t, "helper", nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+
+	if _, err := p.Upload(context.Background(), []byte("x")); !errors.Is(err, ErrPluginExited) {
+		t.Fatalf("Upload() after Close() error = %v, want ErrPluginExited", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+
+	files := map[string]os.FileMode{
+		filepath.Join(first, Prefix+"a"):    0o755,
+		filepath.Join(first, Prefix+"b"):    0o644,
+		filepath.Join(first, Prefix+"c.d"):  0o755,
+		filepath.Join(first, "other"):       0o755,
+		filepath.Join(second, Prefix+"a"):   0o755,
+		filepath.Join(second, Prefix+"e"):   0o755,
+		filepath.Join(second, Prefix+"f:g"): 0o755,
+	}
+	for path, mode := range files {
+		if err := os.WriteFile(path, nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := find([]string{first, second, filepath.Join(first, "missing")})
+	want := map[string]string{
+		"a": filepath.Join(first, Prefix+"a"),
+		"e": filepath.Join(second, Prefix+"e"),
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("find() = %v, want %v", got, want)
+	}
+	for name, path := range want {
+		if got[name] != path {
+			t.Fatalf("find() = %v, want %v", got, want)
+		}
+	}
+}
@@ -2,6 +2,11 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/henomis/umbra/internal/content"
@@ -16,21 +21,101 @@ type Provider interface {
 	Expire() time.Duration
 }
 
+// Deleter is implemented by providers able to delete a stored copy.
+type Deleter interface {
+	Delete(ctx context.Context, meta content.Meta) error
+}
+
+// ErrDeleteUnsupported is returned by a Deleter unable to delete copies.
+var ErrDeleteUnsupported = errors.New("provider: delete not supported")
+
+// EncodedSizer is implemented by providers encoding the payload before sending
+// it, whose MaxSize therefore limits the encoded size.
+type EncodedSizer interface {
+	// EncodedSize returns the size of a payload of n bytes once encoded.
+	EncodedSize(n int64) int64
+}
+
+// Capacity returns the size of the largest payload p accepts.
+func Capacity(p Provider) int64 {
+	maxSize := p.MaxSize()
+
+	sizer, ok := p.(EncodedSizer)
+	if !ok {
+		return maxSize
+	}
+
+	// largest payload whose encoded size fits, the encoded size growing with n
+	n := sort.Search(int(maxSize)+1, func(n int) bool {
+		return sizer.EncodedSize(int64(n)) > maxSize
+	})
+
+	return int64(max(n-1, 0))
+}
+
+// EncodedSize returns the size of a payload of n bytes as sent by p.
+func EncodedSize(p Provider, n int64) int64 {
+	if sizer, ok := p.(EncodedSizer); ok {
+		return sizer.EncodedSize(n)
+	}
+
+	return n
+}
+
 // Options represents provider-specific configuration options.
 type Options = map[string]string
 
-// DefaultProviders is the list of providers used if none are specified in the configuration.
-var DefaultProviders = []string{
-	"termbin",
-	"clbin",
-	"pipfi",
-	"pastecnetorg",
+// StatusError is returned by HTTP based providers when the service answers
+// with a non successful status code.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	// RetryAfter is the delay requested by the service through the
+	// Retry-After header, zero when not provided.
+	RetryAfter time.Duration
 }
 
-// Provider names.
-const (
-	TERMBIN      = "termbin"
-	CLBIN        = "clbin"
-	PIPFI        = "pipfi"
-	PASTECNETORG = "pastecnetorg"
-)
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status code %d", e.Provider, e.StatusCode)
+}
+
+// Retryable reports whether the request may succeed if repeated later.
+func (e *StatusError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	default:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+}
+
+// CheckResponse returns a *StatusError when resp has a non 2xx status code.
+func CheckResponse(name string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	return &StatusError{
+		Provider:   name,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value expressed either in
+// seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
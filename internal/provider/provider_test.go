@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+)
+
+func TestCheckResponseSuccess(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+
+	if err := CheckResponse("test", resp); err != nil {
+		t.Fatalf("CheckResponse returned error: %v", err)
+	}
+}
+
+func TestCheckResponseRetryAfterSeconds(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"7"}},
+	}
+
+	err := CheckResponse("test", resp)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("CheckResponse error = %v, want *StatusError", err)
+	}
+
+	if !statusErr.Retryable() {
+		t.Fatal("429 should be retryable")
+	}
+
+	if statusErr.RetryAfter != 7*time.Second {
+		t.Fatalf("RetryAfter = %s, want 7s", statusErr.RetryAfter)
+	}
+}
+
+func TestCheckResponseRetryAfterDate(t *testing.T) {
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	resp := &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"Retry-After": []string{date}},
+	}
+
+	var statusErr *StatusError
+	if !errors.As(CheckResponse("test", resp), &statusErr) {
+		t.Fatal("CheckResponse did not return *StatusError")
+	}
+
+	if statusErr.RetryAfter <= 0 || statusErr.RetryAfter > time.Minute {
+		t.Fatalf("RetryAfter = %s, want within (0, 1m]", statusErr.RetryAfter)
+	}
+}
+
+func TestStatusErrorRetryable(t *testing.T) {
+	tests := map[int]bool{
+		http.StatusBadRequest:            false,
+		http.StatusRequestEntityTooLarge: false,
+		http.StatusRequestTimeout:        true,
+		http.StatusTooManyRequests:       true,
+		http.StatusBadGateway:            true,
+	}
+
+	for code, want := range tests {
+		err := &StatusError{Provider: "test", StatusCode: code}
+		if got := err.Retryable(); got != want {
+			t.Errorf("Retryable() for %d = %v, want %v", code, got, want)
+		}
+	}
+}
+
+type sizedProvider struct {
+	maxSize int64
+}
+
+func (sizedProvider) Name() string { return "sized" }
+
+func (sizedProvider) Upload(context.Context, []byte) (content.Meta, error) { return nil, nil }
+
+func (sizedProvider) Download(context.Context, content.Meta) ([]byte, error) { return nil, nil }
+
+func (p sizedProvider) MaxSize() int64 { return p.maxSize }
+
+func (sizedProvider) Expire() time.Duration { return 0 }
+
+type base64Provider struct{ sizedProvider }
+
+func (base64Provider) EncodedSize(n int64) int64 {
+	return int64(base64.StdEncoding.EncodedLen(int(n)))
+}
+
+func TestCapacity(t *testing.T) {
+	if got := Capacity(sizedProvider{maxSize: 1000}); got != 1000 {
+		t.Fatalf("Capacity() = %d, want 1000", got)
+	}
+
+	// 750 bytes encode to exactly 1000 bytes, 751 to 1004
+	if got := Capacity(base64Provider{sizedProvider{maxSize: 1000}}); got != 750 {
+		t.Fatalf("Capacity() = %d, want 750", got)
+	}
+
+	if got := Capacity(base64Provider{sizedProvider{maxSize: 1003}}); got != 750 {
+		t.Fatalf("Capacity() = %d, want 750", got)
+	}
+}
+
+// unregister removes the named provider from the registry, so that tests
+// registering providers can run again.
+func unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, name)
+}
+
+func TestRegistry(t *testing.T) {
+	Register(Registration{
+		Name:    "test-registry",
+		Default: true,
+		Factory: func(Options) (Provider, error) { return base64Provider{sizedProvider{maxSize: 100}}, nil },
+	})
+	t.Cleanup(func() { unregister("test-registry") })
+
+	r, ok := Lookup("test-registry")
+	if !ok {
+		t.Fatal("Lookup() did not find the registered provider")
+	}
+
+	if !slices.Contains(Defaults(), "test-registry") {
+		t.Fatalf("Defaults() = %v, want test-registry", Defaults())
+	}
+
+	p, err := r.Factory(nil)
+	if err != nil {
+		t.Fatalf("Factory() error = %v", err)
+	}
+
+	renamed := Rename(p, "alias")
+	if renamed.Name() != "alias" {
+		t.Fatalf("Name() = %s, want alias", renamed.Name())
+	}
+
+	if got, want := Capacity(renamed), Capacity(p); got != want {
+		t.Fatalf("Capacity() = %d, want %d as the renamed provider", got, want)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	defaults := Settings{BaseURL: "https://default", Timeout: time.Second, MaxSize: 100}
+	supported := []string{OptionBaseURL, OptionTimeout, OptionMaxSize}
+
+	settings, err := ParseOptions("test", Options{OptionBaseURL: "https://mirror", OptionTimeout: "30s"}, defaults, supported...)
+	if err != nil {
+		t.Fatalf("ParseOptions() error = %v", err)
+	}
+
+	want := Settings{BaseURL: "https://mirror", Timeout: 30 * time.Second, MaxSize: 100}
+	if settings != want {
+		t.Fatalf("ParseOptions() = %+v, want %+v", settings, want)
+	}
+
+	if _, err := ParseOptions("test", Options{OptionEndpoint: "host:1"}, defaults, supported...); !errors.Is(err, ErrUnknownOption) {
+		t.Fatalf("ParseOptions() error = %v, want ErrUnknownOption", err)
+	}
+
+	for _, options := range []Options{{OptionTimeout: "soon"}, {OptionTimeout: "-1s"}, {OptionMaxSize: "0"}} {
+		if _, err := ParseOptions("test", options, defaults, supported...); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("ParseOptions(%v) error = %v, want ErrInvalidOption", options, err)
+		}
+	}
+}
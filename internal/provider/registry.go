@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+)
+
+// Factory creates a provider instance configured with the given options.
+type Factory func(options Options) (Provider, error)
+
+// Capabilities describes the default limits of a provider.
+type Capabilities struct {
+	MaxSize int64
+	// Expire is how long copies are kept, zero if they do not expire.
+	Expire time.Duration
+	// Encoding is the encoding applied to payloads before sending them,
+	// empty if they are sent raw.
+	Encoding string
+}
+
+// Registration describes a provider available to umbra.
+type Registration struct {
+	Name         string
+	Description  string
+	Capabilities Capabilities
+	// Options are the option keys accepted by the factory.
+	Options []string
+	// Default marks the providers used when none are configured.
+	Default bool
+	Factory Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Registration)
+)
+
+// Register makes a provider available by name. It is meant to be called from
+// the init function of provider packages and panics if the registration is
+// incomplete or the name is already registered.
+func Register(r Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if r.Name == "" || r.Factory == nil {
+		panic("provider: incomplete registration")
+	}
+
+	if _, ok := registry[r.Name]; ok {
+		panic(fmt.Sprintf("provider: %s registered twice", r.Name))
+	}
+
+	registry[r.Name] = r
+}
+
+// Lookup returns the registration of the named provider.
+func Lookup(name string) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Registrations returns every registered provider sorted by name.
+func Registrations() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	registrations := make([]Registration, 0, len(registry))
+	for _, r := range registry {
+		registrations = append(registrations, r)
+	}
+
+	slices.SortFunc(registrations, func(a, b Registration) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return registrations
+}
+
+// Defaults returns the names of the providers used when none are configured,
+// sorted by name.
+func Defaults() []string {
+	var names []string
+	for _, r := range Registrations() {
+		if r.Default {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// Rename returns p reporting name as its name, so that the copies it stores
+// are recorded under an alias.
+func Rename(p Provider, name string) Provider {
+	return &renamed{Provider: p, name: name}
+}
+
+type renamed struct {
+	Provider
+	name string
+}
+
+func (r *renamed) Name() string {
+	return r.name
+}
+
+func (r *renamed) EncodedSize(n int64) int64 {
+	return EncodedSize(r.Provider, n)
+}
+
+func (r *renamed) Delete(ctx context.Context, meta content.Meta) error {
+	if deleter, ok := r.Provider.(Deleter); ok {
+		return deleter.Delete(ctx, meta)
+	}
+	return ErrDeleteUnsupported
+}
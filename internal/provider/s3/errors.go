@@ -0,0 +1,18 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error definitions.
+var (
+	ErrBucketMissing      = errors.New("s3: bucket option required")
+	ErrCredentialsMissing = errors.New("s3: credentials required")
+	ErrMetaInvalid        = errors.New("s3: bucket or key missing in meta")
+	ErrBodyReadFailed     = errors.New("s3: unable to read response body")
+)
+
+func wrapBodyReadErr(err error) error {
+	return errors.Join(ErrBodyReadFailed, fmt.Errorf("read body: %w", err))
+}
@@ -0,0 +1,311 @@
+// Package s3 implements a provider storing the payloads as objects of a
+// bucket of an S3-compatible object storage, such as AWS S3 or MinIO. The
+// requests are signed with AWS Signature Version 4.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/provider"
+)
+
+// Name is the name the provider is registered with.
+const Name = "s3"
+
+// Option keys of the s3 provider.
+const (
+	OptionRegion       = "region"
+	OptionBucket       = "bucket"
+	OptionPrefix       = "prefix"
+	OptionAccessKey    = "access_key"
+	OptionSecretKey    = "secret_key"
+	OptionSessionToken = "session_token"
+	OptionPathStyle    = "path_style"
+)
+
+// Environment variables holding the default credentials.
+const (
+	envAccessKey    = "AWS_ACCESS_KEY_ID"
+	envSecretKey    = "AWS_SECRET_ACCESS_KEY"
+	envSessionToken = "AWS_SESSION_TOKEN"
+	envRegion       = "AWS_REGION"
+)
+
+const (
+	expire        = 0
+	defaultRegion = "us-east-1"
+	// maxSizeBytes is the largest object a single PUT request uploads.
+	maxSizeBytes   = 5 * 1024 * 1024 * 1024
+	defaultTimeout = 5 * time.Minute
+	contentType    = "application/octet-stream"
+)
+
+var (
+	_ provider.Provider = (*S3)(nil)
+	_ provider.Deleter  = (*S3)(nil)
+)
+
+// S3 implements the Provider interface over a bucket.
+type S3 struct {
+	client    *http.Client
+	signer    *signer
+	endpoint  *url.URL
+	bucket    string
+	prefix    string
+	pathStyle bool
+	userAgent string
+	maxSize   int64
+	now       func() time.Time
+}
+
+// Meta holds the metadata for S3 uploads.
+type Meta struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+func init() {
+	provider.Register(provider.Registration{
+		Name:        Name,
+		Description: "S3-compatible object storage",
+		Capabilities: provider.Capabilities{
+			MaxSize: maxSizeBytes,
+			Expire:  expire,
+		},
+		Options: SupportedOptions,
+		Factory: func(options provider.Options) (provider.Provider, error) {
+			return New(options)
+		},
+	})
+}
+
+// SupportedOptions are the option keys accepted by New. The bucket is
+// required. The credentials and the region default to the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION environment
+// variables, and the endpoint to the AWS one of the region.
+var SupportedOptions = []string{
+	provider.OptionEndpoint,
+	OptionRegion,
+	OptionBucket,
+	OptionPrefix,
+	OptionAccessKey,
+	OptionSecretKey,
+	OptionSessionToken,
+	OptionPathStyle,
+	provider.OptionTimeout,
+	provider.OptionUserAgent,
+	provider.OptionMaxSize,
+}
+
+// New creates a new S3 provider instance configured with options.
+func New(options provider.Options) (*S3, error) {
+	region := optionOrEnv(options, OptionRegion, envRegion)
+	if region == "" {
+		region = defaultRegion
+	}
+
+	settings, err := provider.ParseOptions(Name, options, provider.Settings{
+		Endpoint: "https://s3." + region + ".amazonaws.com",
+		Timeout:  defaultTimeout,
+		MaxSize:  maxSizeBytes,
+	}, SupportedOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &S3{
+		client: &http.Client{
+			Timeout: settings.Timeout,
+		},
+		signer: &signer{
+			accessKey:    optionOrEnv(options, OptionAccessKey, envAccessKey),
+			secretKey:    optionOrEnv(options, OptionSecretKey, envSecretKey),
+			sessionToken: optionOrEnv(options, OptionSessionToken, envSessionToken),
+			region:       region,
+		},
+		bucket:    options[OptionBucket],
+		prefix:    options[OptionPrefix],
+		userAgent: settings.UserAgent,
+		maxSize:   settings.MaxSize,
+		now:       time.Now,
+	}
+
+	if s.bucket == "" {
+		return nil, fmt.Errorf("%w: %s.%s", ErrBucketMissing, Name, OptionBucket)
+	}
+
+	if s.signer.accessKey == "" || s.signer.secretKey == "" {
+		return nil, fmt.Errorf("%w: %s.%s and %s.%s", ErrCredentialsMissing, Name, OptionAccessKey, Name, OptionSecretKey)
+	}
+
+	s.endpoint, err = url.Parse(settings.Endpoint)
+	if err != nil || (s.endpoint.Scheme != "http" && s.endpoint.Scheme != "https") || s.endpoint.Host == "" {
+		return nil, invalidOption(provider.OptionEndpoint, settings.Endpoint)
+	}
+
+	if value, ok := options[OptionPathStyle]; ok {
+		s.pathStyle, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, invalidOption(OptionPathStyle, value)
+		}
+	}
+
+	return s, nil
+}
+
+// optionOrEnv returns the option value, or the environment variable when the
+// option is not set.
+func optionOrEnv(options provider.Options, key, env string) string {
+	if value, ok := options[key]; ok {
+		return value
+	}
+	return os.Getenv(env)
+}
+
+func invalidOption(key, value string) error {
+	return fmt.Errorf("%w %s.%s=%q", provider.ErrInvalidOption, Name, key, value)
+}
+
+// Name returns the provider name.
+func (s *S3) Name() string {
+	return Name
+}
+
+// MaxSize returns the maximum allowed size for uploads.
+func (s *S3) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Expire returns the expiration duration for uploads.
+func (s *S3) Expire() time.Duration {
+	return expire
+}
+
+// Upload stores the payload as a randomly named object of the bucket.
+func (s *S3) Upload(ctx context.Context, payload []byte) (content.Meta, error) {
+	meta := Meta{
+		Bucket: s.bucket,
+		Key:    s.prefix + strings.ToLower(rand.Text()),
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, meta, payload)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return json.Marshal(meta)
+}
+
+// Download fetches the object stored in Meta.
+func (s *S3) Download(ctx context.Context, meta content.Meta) ([]byte, error) {
+	m, err := parseMeta(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, m, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, wrapBodyReadErr(err)
+	}
+
+	return data, nil
+}
+
+// Delete removes the object stored in Meta.
+func (s *S3) Delete(ctx context.Context, meta content.Meta) error {
+	m, err := parseMeta(meta)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, m, nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func parseMeta(meta content.Meta) (Meta, error) {
+	m := Meta{}
+	if err := json.Unmarshal(meta, &m); err != nil {
+		return m, err
+	}
+
+	if m.Bucket == "" || m.Key == "" {
+		return m, ErrMetaInvalid
+	}
+
+	return m, nil
+}
+
+// objectURL returns the URL of the object, with the bucket in the path or in
+// the host name.
+func (s *S3) objectURL(m Meta) *url.URL {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+
+	if s.pathStyle {
+		u.Path = base + "/" + m.Bucket + "/" + m.Key
+	} else {
+		u.Host = m.Bucket + "." + u.Host
+		u.Path = base + "/" + m.Key
+	}
+
+	// sent exactly as signed
+	u.RawPath = uriEncode(u.Path, false)
+
+	return &u
+}
+
+// do sends a signed request for the object and returns the successful
+// response, whose body the caller closes.
+func (s *S3) do(ctx context.Context, method string, m Meta, payload []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(m).String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	if payload == nil {
+		req.Body, req.ContentLength = http.NoBody, 0
+	} else {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if s.userAgent != "" {
+		req.Header.Set("User-Agent", s.userAgent)
+	}
+
+	s.signer.sign(req, hashHex(payload), s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := provider.CheckResponse(Name, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp, nil
+}
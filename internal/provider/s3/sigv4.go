@@ -0,0 +1,145 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Signature Version 4 constants.
+const (
+	algorithm       = "AWS4-HMAC-SHA256"
+	service         = "s3"
+	scopeTerminal   = "aws4_request"
+	amzDateFormat   = "20060102T150405Z"
+	dateFormat      = "20060102"
+	headerDate      = "X-Amz-Date"
+	headerSHA256    = "X-Amz-Content-Sha256"
+	headerToken     = "X-Amz-Security-Token"
+	headerAuthorize = "Authorization"
+)
+
+// signer signs requests with AWS Signature Version 4.
+type signer struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+}
+
+// sign adds the authentication headers to req, whose body hashes to
+// payloadHash, as of t. Every header already set on req is signed, along with
+// the host.
+func (s *signer) sign(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format(amzDateFormat)
+
+	req.Header.Set(headerDate, amzDate)
+	req.Header.Set(headerSHA256, payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set(headerToken, s.sessionToken)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(path, false),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format(dateFormat), s.region, service, scopeTerminal}, "/")
+
+	stringToSign := strings.Join([]string{
+		algorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format(dateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, scopeTerminal)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set(headerAuthorize, fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, s.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalHeaders returns the canonical headers block, lowercase names with
+// trimmed values sorted by name, and the list of the signed header names.
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string]string{"host": req.URL.Host}
+	for name, value := range req.Header {
+		values[strings.ToLower(name)] = strings.Join(strings.Fields(strings.Join(value, ",")), " ")
+	}
+
+	names := slices.Sorted(maps.Keys(values))
+
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + values[name] + "\n")
+	}
+
+	return headers.String(), strings.Join(names, ";")
+}
+
+// canonicalQuery returns the query parameters encoded and sorted by name, then
+// by value.
+func canonicalQuery(query url.Values) string {
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	slices.Sort(params)
+
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters,
+// and the slashes unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := range len(s) {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,47 @@
+package selection
+
+import (
+	"sync"
+	"time"
+)
+
+// smoothing is the weight of the newest sample in the moving averages.
+const smoothing = 0.3
+
+// MovingAverages keeps an exponentially weighted moving average of the
+// durations observed for each provider. It is safe for concurrent use.
+type MovingAverages struct {
+	mu       sync.Mutex
+	averages map[string]time.Duration
+}
+
+// NewMovingAverages returns moving averages with no provider observed yet.
+func NewMovingAverages() *MovingAverages {
+	return &MovingAverages{
+		averages: make(map[string]time.Duration),
+	}
+}
+
+// Observe adds a sample of d to the average of the named provider.
+func (m *MovingAverages) Observe(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	avg, ok := m.averages[name]
+	if !ok {
+		m.averages[name] = d
+		return
+	}
+
+	m.averages[name] = time.Duration(smoothing*float64(d) + (1-smoothing)*float64(avg))
+}
+
+// Average returns the average of the named provider, and whether it was
+// observed.
+func (m *MovingAverages) Average(name string) (time.Duration, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	avg, ok := m.averages[name]
+	return avg, ok
+}
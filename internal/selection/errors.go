@@ -0,0 +1,8 @@
+package selection
+
+import "errors"
+
+// Selection errors.
+var (
+	ErrUnknownPolicy = errors.New("selection: unknown policy")
+)
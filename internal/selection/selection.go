@@ -0,0 +1,207 @@
+package selection
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/henomis/umbra/internal/provider"
+)
+
+// Supported selection policies. The empty name selects Random.
+const (
+	Random      = "random"
+	RoundRobin  = "round-robin"
+	Weighted    = "weighted"
+	LeastLoaded = "least-loaded"
+	Latency     = "latency"
+)
+
+var policies = []string{Random, RoundRobin, Weighted, LeastLoaded, Latency}
+
+// Policies returns the list of supported selection policies.
+func Policies() []string {
+	return policies
+}
+
+// IsValidPolicy checks if the provided name is a valid selection policy.
+func IsValidPolicy(name string) bool {
+	return name == "" || slices.Contains(policies, name)
+}
+
+// Policy picks the provider receiving the next copy of a chunk. Policies are
+// safe for concurrent use.
+type Policy interface {
+	// Select returns one of candidates, which is never empty, to store a copy
+	// of size bytes.
+	Select(candidates []provider.Provider, size int64) provider.Provider
+	// Observe records a successful upload of size bytes to the named provider
+	// that took elapsed.
+	Observe(name string, size int64, elapsed time.Duration)
+}
+
+// New returns the named policy. Providers lists the provider names in their
+// configured order, used by RoundRobin, and weights the relative weight of the
+// providers, used by Weighted. Providers without a weight have weight 1.
+func New(name string, providers []string, weights map[string]int) (Policy, error) {
+	switch name {
+	case "", Random:
+		return &random{}, nil
+	case RoundRobin:
+		return &roundRobin{providers: providers}, nil
+	case Weighted:
+		return &weighted{weights: weights}, nil
+	case LeastLoaded:
+		return &leastLoaded{load: make(map[string]int64)}, nil
+	case Latency:
+		return &latency{averages: NewMovingAverages()}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPolicy, name)
+	}
+}
+
+// random picks candidates uniformly at random.
+type random struct{}
+
+func (*random) Select(candidates []provider.Provider, _ int64) provider.Provider {
+	return candidates[rand.IntN(len(candidates))]
+}
+
+func (*random) Observe(string, int64, time.Duration) {}
+
+// roundRobin cycles through the providers in their configured order, skipping
+// the ones that are not candidates.
+type roundRobin struct {
+	mu        sync.Mutex
+	providers []string
+	next      int
+}
+
+func (r *roundRobin) Select(candidates []provider.Provider, _ int64) provider.Provider {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for range r.providers {
+		name := r.providers[r.next%len(r.providers)]
+		r.next++
+
+		if i := slices.IndexFunc(candidates, func(p provider.Provider) bool { return p.Name() == name }); i >= 0 {
+			return candidates[i]
+		}
+	}
+
+	return candidates[0]
+}
+
+func (*roundRobin) Observe(string, int64, time.Duration) {}
+
+// weighted picks candidates at random with a probability proportional to
+// their weight.
+type weighted struct {
+	weights map[string]int
+}
+
+func (w *weighted) weight(name string) int {
+	if weight, ok := w.weights[name]; ok {
+		return weight
+	}
+	return 1
+}
+
+func (w *weighted) Select(candidates []provider.Provider, _ int64) provider.Provider {
+	total := 0
+	for _, p := range candidates {
+		total += w.weight(p.Name())
+	}
+
+	n := rand.IntN(total)
+	for _, p := range candidates {
+		n -= w.weight(p.Name())
+		if n < 0 {
+			return p
+		}
+	}
+
+	return candidates[len(candidates)-1]
+}
+
+func (*weighted) Observe(string, int64, time.Duration) {}
+
+// leastLoaded picks the candidate that was assigned the fewest bytes. Bytes
+// are accounted on selection, so that concurrent uploads spread evenly.
+type leastLoaded struct {
+	mu   sync.Mutex
+	load map[string]int64
+}
+
+func (l *leastLoaded) Select(candidates []provider.Provider, size int64) provider.Provider {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	selected := slices.MinFunc(candidates, func(a, b provider.Provider) int {
+		return cmp.Compare(l.load[a.Name()], l.load[b.Name()])
+	})
+	l.load[selected.Name()] += size
+
+	return selected
+}
+
+func (*leastLoaded) Observe(string, int64, time.Duration) {}
+
+// latency picks the candidate with the lowest moving average of the upload
+// time per byte. Providers not observed yet are picked first, so that they
+// get measured.
+type latency struct {
+	averages *MovingAverages
+}
+
+func (l *latency) Select(candidates []provider.Provider, _ int64) provider.Provider {
+	var fastest []provider.Provider
+	var best time.Duration
+	for _, p := range candidates {
+		d, _ := l.averages.Average(p.Name())
+		switch {
+		case len(fastest) == 0 || d < best:
+			fastest = []provider.Provider{p}
+			best = d
+		case d == best:
+			fastest = append(fastest, p)
+		}
+	}
+
+	return fastest[rand.IntN(len(fastest))]
+}
+
+func (l *latency) Observe(name string, size int64, elapsed time.Duration) {
+	// normalized per KiB, so that chunks of different sizes compare, and
+	// never zero, which is the average of the providers not observed yet
+	perKiB := elapsed * 1024 / time.Duration(max(size, 1))
+
+	l.averages.Observe(name, max(perKiB, 1))
+}
+
+// Tags maps provider names to their tags, such as the operator or the
+// jurisdiction of the service.
+type Tags map[string]map[string]string
+
+// Distinct reports whether candidate shares no value of the given tag keys
+// with the holders. Providers without a tag never conflict on it.
+func (t Tags) Distinct(candidate string, holders []string, keys []string) bool {
+	for _, key := range keys {
+		value, ok := t[candidate][key]
+		if !ok {
+			continue
+		}
+
+		for _, holder := range holders {
+			if held, ok := t[holder][key]; ok && held == value {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,147 @@
+package selection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/provider"
+)
+
+type namedProvider string
+
+func (p namedProvider) Name() string { return string(p) }
+
+func (namedProvider) Upload(context.Context, []byte) (content.Meta, error) { return nil, nil }
+
+func (namedProvider) Download(context.Context, content.Meta) ([]byte, error) { return nil, nil }
+
+func (namedProvider) MaxSize() int64 { return 0 }
+
+func (namedProvider) Expire() time.Duration { return 0 }
+
+func providers(names ...string) []provider.Provider {
+	ps := make([]provider.Provider, len(names))
+	for i, name := range names {
+		ps[i] = namedProvider(name)
+	}
+	return ps
+}
+
+func selectN(p Policy, candidates []provider.Provider, n int) map[string]int {
+	counts := make(map[string]int)
+	for range n {
+		counts[p.Select(candidates, 100).Name()]++
+	}
+	return counts
+}
+
+func TestNewUnknownPolicy(t *testing.T) {
+	if _, err := New("fastest", nil, nil); !errors.Is(err, ErrUnknownPolicy) {
+		t.Fatalf("New() error = %v, want ErrUnknownPolicy", err)
+	}
+}
+
+func TestRoundRobinSkipsNonCandidates(t *testing.T) {
+	p, _ := New(RoundRobin, []string{"a", "b", "c"}, nil)
+	candidates := providers("a", "c")
+
+	var got []string
+	for range 4 {
+		got = append(got, p.Select(candidates, 1).Name())
+	}
+
+	want := []string{"a", "c", "a", "c"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("selected %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWeightedFollowsWeights(t *testing.T) {
+	p, _ := New(Weighted, nil, map[string]int{"a": 9})
+	counts := selectN(p, providers("a", "b"), 10000)
+
+	if counts["a"] < 8500 || counts["b"] < 500 {
+		t.Fatalf("selections = %v, want about 9:1", counts)
+	}
+}
+
+func TestLeastLoadedBalancesBytes(t *testing.T) {
+	p, _ := New(LeastLoaded, nil, nil)
+	candidates := providers("a", "b")
+
+	if got := p.Select(candidates, 300).Name(); got != "a" {
+		t.Fatalf("first selection = %s, want a", got)
+	}
+
+	// b stays the least loaded until it holds as many bytes as a
+	for range 3 {
+		if got := p.Select(candidates, 100).Name(); got != "b" {
+			t.Fatalf("selection = %s, want b", got)
+		}
+	}
+}
+
+func TestLatencyPrefersFastest(t *testing.T) {
+	p, _ := New(Latency, nil, nil)
+	candidates := providers("slow", "fast", "new")
+
+	p.Observe("slow", 1024, time.Second)
+	p.Observe("fast", 1024, time.Millisecond)
+
+	if got := p.Select(candidates, 1024).Name(); got != "new" {
+		t.Fatalf("selection = %s, want the unmeasured provider", got)
+	}
+
+	p.Observe("new", 1024, 500*time.Millisecond)
+
+	if got := p.Select(candidates, 1024).Name(); got != "fast" {
+		t.Fatalf("selection = %s, want fast", got)
+	}
+}
+
+func TestTagsDistinct(t *testing.T) {
+	tags := Tags{
+		"a": {"jurisdiction": "de", "operator": "x"},
+		"b": {"jurisdiction": "de"},
+		"c": {"jurisdiction": "us", "operator": "x"},
+	}
+
+	tests := []struct {
+		candidate string
+		holders   []string
+		keys      []string
+		want      bool
+	}{
+		{"b", []string{"a"}, []string{"jurisdiction"}, false},
+		{"c", []string{"a"}, []string{"jurisdiction"}, true},
+		{"c", []string{"a"}, []string{"jurisdiction", "operator"}, false},
+		{"b", []string{"a"}, nil, true},
+		{"d", []string{"a"}, []string{"jurisdiction"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tags.Distinct(tt.candidate, tt.holders, tt.keys); got != tt.want {
+			t.Errorf("Distinct(%s, %v, %v) = %v, want %v", tt.candidate, tt.holders, tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestMovingAverages(t *testing.T) {
+	m := NewMovingAverages()
+
+	if _, ok := m.Average("p"); ok {
+		t.Fatal("Average() of an unobserved provider reported as observed")
+	}
+
+	m.Observe("p", 100*time.Millisecond)
+	m.Observe("p", 200*time.Millisecond)
+
+	if avg, ok := m.Average("p"); !ok || avg != 130*time.Millisecond {
+		t.Fatalf("Average() = %s, %t, want 130ms, true", avg, ok)
+	}
+}
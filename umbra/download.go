@@ -9,73 +9,214 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/vbauerster/mpb/v8"
-	"github.com/vbauerster/mpb/v8/decor"
-
+	"github.com/henomis/umbra/internal/compress"
 	"github.com/henomis/umbra/internal/content"
 	"github.com/henomis/umbra/internal/crypto"
 	"github.com/henomis/umbra/internal/ghost"
 	"github.com/henomis/umbra/internal/manifest"
 )
 
+// partialSuffix is appended to the output path to name the file being written.
+const partialSuffix = ".partial"
+
 // Download orchestrates the manifest reading, decryption setup, content retrieval, and
 // output file reconstruction for the configured Umbra instance.
 func (u *Umbra) Download(ctx context.Context) error {
-	// read manifest data
-	manifestData, err := u.getManifestData(ctx)
+	content, crypto, err := u.loadContent(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get manifest data: %w", err)
+		return err
 	}
 
-	// create crypto and decode manifest
-	crypto, err := crypto.New([]byte(u.config.Password))
-	if err != nil {
-		return fmt.Errorf("failed to create crypto: %w", err)
+	// standard output is written sequentially
+	if u.config.Download.IsStream() {
+		return u.downloadStream(ctx, content, crypto)
 	}
 
-	// decode manifest
-	manifest := manifest.New(crypto)
-	contentData, err := manifest.Decode(bytes.NewReader(manifestData))
-	if err != nil {
-		return fmt.Errorf("failed to decode manifest: %w", err)
+	// never clobber an existing file unless asked to
+	outputPath := u.config.Download.OutputFilePath
+	if !u.config.Download.Force {
+		if _, err := os.Stat(outputPath); err == nil {
+			return ErrOutputFileExists
+		}
 	}
 
-	// create content from decoded data
-	content, err := content.NewFromData(contentData)
-	if err != nil {
-		return fmt.Errorf("failed to create content from data: %w", err)
+	// the output is written to a partial file next to it, and only renamed once verified
+	partialPath := outputPath + partialSuffix
+
+	// create partial file, keeping its content when resuming
+	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if u.config.Download.Resume {
+		flags = os.O_RDWR | os.O_CREATE
 	}
 
-	// create output file
-	outputFile, err := os.Create(u.config.Download.OutputFilePath)
+	partialFile, err := os.OpenFile(partialPath, flags, 0o644)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
-	defer outputFile.Close()
+	defer partialFile.Close()
+
+	if u.config.Download.IsRange() {
+		err = u.extractRange(ctx, content, crypto, partialFile, u.config.Download.Offset, u.config.Download.Length)
+		if err != nil {
+			partialFile.Close()
+			os.Remove(partialPath)
+			return fmt.Errorf("failed to extract range: %w", err)
+		}
+
+		return u.commitOutput(partialFile, partialPath, outputPath)
+	}
 
-	// process content
-	err = u.extractContent(ctx, content, crypto, outputFile)
+	// find the chunks already written by an interrupted download
+	var present []bool
+	if u.config.Download.Resume {
+		present, err = presentChunks(content, partialFile)
+		if err != nil {
+			return fmt.Errorf("failed to inspect output file: %w", err)
+		}
+	}
+
+	// process content, chunks are verified as they are written
+	err = u.extractContentAt(ctx, content, crypto, partialFile, present)
 	if err != nil {
+		u.printPartialKept(partialPath)
 		return fmt.Errorf("failed to extract content: %w", err)
 	}
 
-	outputFileHash, err := fileSHA256(u.config.Download.OutputFilePath)
+	// final pass over the whole file
+	outputFileHash, err := fileSHA256(partialPath)
 	if err != nil {
 		return fmt.Errorf("failed to compute output file hash: %w", err)
 	}
 
 	if outputFileHash != content.Hash {
+		partialFile.Close()
+		os.Remove(partialPath)
 		return ErrOutputFileHashMismatch
 	}
 
-	if !u.config.Quiet {
-		fmt.Printf("✅ Download completed. Output file: '%s'\n", u.config.Download.OutputFilePath)
+	return u.commitOutput(partialFile, partialPath, outputPath)
+}
+
+// commitOutput flushes the verified partial file to disk and moves it to the
+// output path, replacing any existing file.
+func (u *Umbra) commitOutput(partialFile *os.File, partialPath, outputPath string) error {
+	if err := partialFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output file: %w", err)
 	}
 
+	if err := partialFile.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+
+	if err := os.Rename(partialPath, outputPath); err != nil {
+		return fmt.Errorf("failed to rename output file: %w", err)
+	}
+
+	u.printDownloadCompleted()
 	return nil
 }
 
+// downloadStream writes the content, or the configured range of it, to
+// standard output in order.
+func (u *Umbra) downloadStream(ctx context.Context, content *content.Content, crypto *crypto.Crypto) error {
+	if u.config.Download.IsRange() {
+		err := u.extractRange(ctx, content, crypto, os.Stdout, u.config.Download.Offset, u.config.Download.Length)
+		if err != nil {
+			return fmt.Errorf("failed to extract range: %w", err)
+		}
+
+		u.printDownloadCompleted()
+		return nil
+	}
+
+	// process content, hashing the output while it is written
+	outputHash := sha256.New()
+	err := u.extractContent(ctx, content, crypto, io.MultiWriter(os.Stdout, outputHash))
+	if err != nil {
+		return fmt.Errorf("failed to extract content: %w", err)
+	}
+
+	if !bytes.Equal(outputHash.Sum(nil), content.Hash[:]) {
+		return ErrOutputFileHashMismatch
+	}
+
+	u.printDownloadCompleted()
+	return nil
+}
+
+// ReadRange writes length bytes starting at offset of the stored file to w. Only
+// the chunks overlapping the range are downloaded, and each of them is verified
+// against its hash before being written. A zero length reads up to the end of
+// the file.
+func (u *Umbra) ReadRange(ctx context.Context, w io.Writer, offset, length int64) error {
+	content, crypto, err := u.loadContent(ctx)
+	if err != nil {
+		return err
+	}
+
+	return u.extractRange(ctx, content, crypto, w, offset, length)
+}
+
+func (u *Umbra) printPartialKept(partialPath string) {
+	if u.config.Quiet {
+		return
+	}
+
+	fmt.Fprintf(u.output, "💾 Partial output kept at '%s', run again with --resume to continue\n", partialPath)
+}
+
+func (u *Umbra) printDownloadCompleted() {
+	if u.config.Quiet {
+		return
+	}
+
+	if u.config.Download.IsStream() {
+		fmt.Fprintln(u.output, "✅ Download completed. Output written to standard output")
+		return
+	}
+
+	fmt.Fprintf(u.output, "✅ Download completed. Output file: '%s'\n", u.config.Download.OutputFilePath)
+}
+
+// loadContent reads and decrypts the configured manifest, returning the stored
+// content together with the crypto helper needed to decrypt its chunks.
+func (u *Umbra) loadContent(ctx context.Context) (*content.Content, *crypto.Crypto, error) {
+	// read manifest data
+	manifestData, err := u.getManifestData(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get manifest data: %w", err)
+	}
+
+	// create crypto and decode manifest
+	crypto, err := crypto.New([]byte(u.config.Password))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create crypto: %w", err)
+	}
+
+	// decode manifest
+	manifest := manifest.New(crypto)
+	contentData, err := manifest.Decode(bytes.NewReader(manifestData))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+
+	// create content from decoded data
+	content, err := content.NewFromData(contentData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create content from data: %w", err)
+	}
+
+	// verify chunk list against the merkle root
+	if content.HasRoot() && !content.VerifyRoot() {
+		return nil, nil, ErrMerkleRootMismatch
+	}
+
+	return content, crypto, nil
+}
+
 func (u *Umbra) getManifestData(ctx context.Context) ([]byte, error) {
 	// read manifest data based on ghost mode
 	var data io.Reader
@@ -142,25 +283,40 @@ func (u *Umbra) getManifestFromProvider(ctx context.Context) (io.Reader, error)
 	return bytes.NewReader(data), nil
 }
 
-func (u *Umbra) extractContent(ctx context.Context, content *content.Content, crypto *crypto.Crypto, outputFile *os.File) error {
-	var bar *mpb.Bar
+func (u *Umbra) extractContent(ctx context.Context, content *content.Content, crypto *crypto.Crypto, w io.Writer) error {
+	bar := u.newProgressBar("Downloading: ", int64(len(content.Chunks)))
 
-	if !u.config.Quiet {
-		bar = u.progress.New(
-			int64(len(content.Chunks)),
-			mpb.BarStyle().Rbound("|"),
-			mpb.PrependDecorators(
-				decor.Name("Downloading: ", decor.WC{W: 12}),
-				decor.CountersNoUnit("%d/%d", decor.WCSyncWidth),
-			),
-			mpb.AppendDecorators(
-				decor.Percentage(),
-			),
-		)
+	// keep the data of chunks referenced by later ones, so that repeated
+	// chunks are copied locally instead of being downloaded again
+	referenced := make(map[uint32][]byte)
+	for _, chunk := range content.Chunks {
+		if chunk.Ref != 0 {
+			referenced[chunk.Ref] = nil
+		}
 	}
 
 	for _, chunk := range content.Chunks {
-		err := u.extractChunk(ctx, &chunk, crypto, outputFile)
+		var chunkData []byte
+		var err error
+
+		switch {
+		case chunk.Zero:
+			err = writeZeros(w, chunk.Size)
+		case referenced[chunk.Ref] != nil:
+			_, err = w.Write(referenced[chunk.Ref])
+		default:
+			chunkData, err = u.extractChunk(ctx, content, &chunk, crypto)
+			if err != nil {
+				return err
+			}
+
+			if _, ok := referenced[chunk.ID]; ok {
+				referenced[chunk.ID] = chunkData
+			}
+
+			_, err = w.Write(chunkData)
+		}
+
 		if err != nil {
 			return err
 		}
@@ -177,44 +333,395 @@ func (u *Umbra) extractContent(ctx context.Context, content *content.Content, cr
 	return nil
 }
 
-func (u *Umbra) extractChunk(ctx context.Context, chunk *content.Chunk, crypto *crypto.Crypto, outputFile *os.File) error {
-	var chunkErr error
+// extractContentAt downloads the content chunks concurrently, using the
+// configured number of workers, and writes each of them at its offset in
+// outputFile. Zero chunks are left as holes and repeated chunks are copied
+// from the data of the chunk they reference. When resuming, present reports
+// the chunks already found in outputFile, which are not downloaded again.
+func (u *Umbra) extractContentAt(ctx context.Context, content *content.Content, crypto *crypto.Crypto, outputFile *os.File, present []bool) error {
+	bar := u.newProgressBar("Downloading: ", int64(len(content.Chunks)))
 
-	for _, c := range chunk.Copies {
-		provider, err := u.getProviderByName(c.Provider)
-		if err != nil {
-			chunkErr = err
-			continue
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, max(u.config.Download.Parallel, 1))
+
+	isPresent := func(i int) bool {
+		return present != nil && present[i]
+	}
+
+	// offsets of the missing chunks repeating each referenced chunk
+	refOffsets := make(map[uint32][]int64)
+	var offset int64
+	for i, chunk := range content.Chunks {
+		if chunk.Ref != 0 && !isPresent(i) {
+			refOffsets[chunk.Ref] = append(refOffsets[chunk.Ref], offset)
 		}
+		offset += chunk.Size
+	}
 
-		encryptedChunkData, err := provider.Download(ctx, c.Meta)
-		if err != nil {
-			chunkErr = err
+	offset = 0
+	for i, chunk := range content.Chunks {
+		chunkOffset := offset
+		offset += chunk.Size
+
+		if isPresent(i) {
+			if bar != nil {
+				bar.Increment()
+			}
+		}
+
+		if chunk.Zero {
+			// left as holes, unless overwriting a previous content
+			if present != nil && !present[i] {
+				if _, err := outputFile.WriteAt(make([]byte, chunk.Size), chunkOffset); err != nil {
+					cancel(err)
+					break
+				}
+			}
+
+			if bar != nil && !isPresent(i) {
+				bar.Increment()
+			}
 			continue
 		}
 
-		chunkData, err := crypto.Decode(encryptedChunkData, chunk.Hash[:])
-		if err != nil {
-			chunkErr = err
+		// written together with the referenced chunk
+		if chunk.Ref != 0 {
 			continue
 		}
 
-		chunkDataHash := sha256.Sum256(chunkData)
-		if chunkDataHash != chunk.Hash {
-			chunkErr = fmt.Errorf("chunk hash mismatch")
+		targets := refOffsets[chunk.ID]
+		if !isPresent(i) {
+			targets = append([]int64{chunkOffset}, targets...)
+		}
+
+		if len(targets) == 0 {
 			continue
 		}
 
-		_, err = outputFile.Write(chunkData)
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Go(func() {
+			defer func() { <-workers }()
+
+			var chunkData []byte
+			var err error
+
+			// present chunks are copied locally to the chunks repeating them
+			if isPresent(i) {
+				chunkData = make([]byte, chunk.Size)
+				_, err = outputFile.ReadAt(chunkData, chunkOffset)
+			} else {
+				chunkData, err = u.extractChunk(ctx, content, &chunk, crypto)
+			}
+
+			if err != nil {
+				cancel(err)
+				return
+			}
+
+			for _, off := range targets {
+				if _, err := outputFile.WriteAt(chunkData, off); err != nil {
+					cancel(err)
+					return
+				}
+
+				if bar != nil {
+					bar.Increment()
+				}
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		if bar != nil {
+			bar.Abort(false)
+			bar.Wait()
+		}
+		return err
+	}
+
+	if bar != nil {
+		bar.Wait()
+	}
+
+	// set the file to its full size, materializing a trailing hole
+	return outputFile.Truncate(content.Size)
+}
+
+// presentChunks reads the output file of an interrupted download and reports,
+// for each chunk, whether the data at its offset already matches its hash.
+func presentChunks(content *content.Content, outputFile *os.File) ([]bool, error) {
+	present := make([]bool, len(content.Chunks))
+
+	var offset int64
+	for i, chunk := range content.Chunks {
+		chunkData := make([]byte, chunk.Size)
+
+		n, err := outputFile.ReadAt(chunkData, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		present[i] = int64(n) == chunk.Size && sha256.Sum256(chunkData) == chunk.Hash
+		offset += chunk.Size
+	}
+
+	return present, nil
+}
+
+// extractRange maps the [offset, offset+length) byte range onto the content
+// chunks, downloads only the overlapping ones and writes the requested bytes to w.
+func (u *Umbra) extractRange(ctx context.Context, content *content.Content, crypto *crypto.Crypto, w io.Writer, offset, length int64) error {
+	if length == 0 {
+		length = content.Size - offset
+	}
+
+	if offset < 0 || length < 0 || offset+length > content.Size {
+		return ErrInvalidRange
+	}
+
+	end := offset + length
+
+	var overlapping int64
+	var chunkStart int64
+	for _, chunk := range content.Chunks {
+		if chunkStart < end && chunkStart+chunk.Size > offset {
+			overlapping++
+		}
+		chunkStart += chunk.Size
+	}
+
+	bar := u.newProgressBar("Downloading: ", overlapping)
+
+	chunkStart = 0
+	for _, chunk := range content.Chunks {
+		chunkEnd := chunkStart + chunk.Size
+		if chunkStart >= end {
+			break
+		}
+
+		if chunkEnd > offset {
+			chunkData, err := u.extractChunk(ctx, content, &chunk, crypto)
+			if err != nil {
+				return err
+			}
+
+			from := max(offset, chunkStart) - chunkStart
+			to := min(end, chunkEnd) - chunkStart
+			if _, err := w.Write(chunkData[from:to]); err != nil {
+				return err
+			}
+
+			if bar != nil {
+				bar.Increment()
+			}
+		}
+
+		chunkStart = chunkEnd
+	}
+
+	if bar != nil {
+		bar.Wait()
+	}
+
+	return nil
+}
+
+// extractChunk downloads the given chunk trying its copies in order, and
+// returns the decrypted data of the first copy matching the chunk hash. Zero
+// chunks are produced locally and referencing chunks are resolved through the
+// chunk holding their data.
+func (u *Umbra) extractChunk(ctx context.Context, content *content.Content, chunk *content.Chunk, crypto *crypto.Crypto) ([]byte, error) {
+	if chunk.Zero {
+		return make([]byte, chunk.Size), nil
+	}
+
+	if chunk.Ref != 0 {
+		refChunk, ok := content.Chunk(chunk.Ref)
+		if !ok || refChunk.Ref != 0 || refChunk.Hash != chunk.Hash {
+			return nil, ErrInvalidChunkRef
+		}
+		chunk = refChunk
+	}
+
+	copies := u.latency.order(chunk.Copies)
+	if u.config.Download != nil && u.config.Download.Hedge > 0 {
+		return u.extractChunkHedged(ctx, chunk, copies, crypto)
+	}
+
+	var chunkErr error
+
+	for _, c := range copies {
+		chunkData, err := u.fetchChunkCopy(ctx, chunk, c, crypto)
 		if err != nil {
 			chunkErr = err
 			continue
 		}
 
-		// successfully processed chunk, break out of the loop
-		chunkErr = nil
-		break
+		// successfully processed chunk
+		return chunkData, nil
+	}
+
+	return nil, chunkErr
+}
+
+// hedgedResult is the outcome of a single hedged chunk copy request.
+type hedgedResult struct {
+	data []byte
+	err  error
+}
+
+// extractChunkHedged requests the first chunk copy and, whenever the configured
+// hedge delay elapses or a request fails, the next one in parallel. The first
+// copy passing decryption and the hash check wins and the pending requests are
+// cancelled.
+func (u *Umbra) extractChunkHedged(ctx context.Context, chunk *content.Chunk, copies []content.ChunkCopy, crypto *crypto.Crypto) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgedResult, len(copies))
+	next, pending := 0, 0
+
+	launch := func() {
+		c := copies[next]
+		next++
+		pending++
+
+		go func() {
+			data, err := u.fetchChunkCopy(ctx, chunk, c, crypto)
+			results <- hedgedResult{data: data, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(u.config.Download.Hedge)
+	defer timer.Stop()
+
+	var chunkErr error
+	if len(copies) > 0 {
+		launch()
+	}
+
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.data, nil
+			}
+
+			chunkErr = r.err
+			if next < len(copies) {
+				launch()
+				timer.Reset(u.config.Download.Hedge)
+			}
+		case <-timer.C:
+			if next < len(copies) {
+				launch()
+				timer.Reset(u.config.Download.Hedge)
+			}
+		}
+	}
+
+	return nil, chunkErr
+}
+
+// fetchChunkCopy downloads a single chunk copy, decrypts and decompresses it,
+// and checks it against the chunk hash.
+func (u *Umbra) fetchChunkCopy(ctx context.Context, chunk *content.Chunk, c content.ChunkCopy, crypto *crypto.Crypto) ([]byte, error) {
+	encryptedChunkData, err := u.downloadChunkCopy(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeChunk(chunk, encryptedChunkData, crypto)
+}
+
+// downloadChunkCopy downloads the encrypted data of a single chunk copy. The
+// latency of successful requests is recorded to order the copies of the
+// following chunks.
+func (u *Umbra) downloadChunkCopy(ctx context.Context, c content.ChunkCopy) ([]byte, error) {
+	provider, err := u.getProviderByName(c.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	// failed requests, and the ones cancelled by a faster hedged copy, end
+	// early and would make the provider look fast
+	start := time.Now()
+	encryptedChunkData, err := provider.Download(ctx, c.Meta)
+	if err != nil {
+		return nil, err
+	}
+	u.latency.Observe(c.Provider, time.Since(start))
+
+	return encryptedChunkData, nil
+}
+
+// decodeChunk decrypts and decompresses the encrypted data of a chunk, and
+// checks it against the chunk hash.
+func decodeChunk(chunk *content.Chunk, encryptedChunkData []byte, crypto *crypto.Crypto) ([]byte, error) {
+	payload, err := crypto.Decode(encryptedChunkData, chunk.Hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	chunkData, err := compress.Decompress(chunk.Compression, payload, chunk.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkDataHash := sha256.Sum256(chunkData)
+	if chunkDataHash != chunk.Hash {
+		return nil, ErrChunkHashMismatch
+	}
+
+	return chunkData, nil
+}
+
+// writeZeros writes n zero bytes to w.
+func writeZeros(w io.Writer, n int64) error {
+	_, err := io.CopyN(w, zeroReader{}, n)
+	return err
+}
+
+// zeroReader is an endless source of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// fileSHA256 computes the SHA-256 hash of the file at the given path and returns
+// it as a fixed-length 32-byte array, along with any error encountered during
+// reading.
+func fileSHA256(path string) ([32]byte, error) {
+	var zero [32]byte
+
+	f, err := os.Open(path)
+	if err != nil {
+		return zero, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return zero, err
 	}
 
-	return chunkErr
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
 }
@@ -0,0 +1,314 @@
+package umbra
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/henomis/umbra/config"
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/provider"
+	"github.com/henomis/umbra/internal/provider/local"
+)
+
+func TestDownloadRange(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(100000)
+
+	manifestPath := uploadTestFile(t, dir, data, &config.Config{
+		Upload: &config.Upload{ChunkSize: 9999, Copies: 1},
+	})
+
+	u := newTestUmbra(t, dir, &config.Config{ManifestPath: manifestPath})
+
+	tests := []struct {
+		name           string
+		offset, length int64
+	}{
+		{"first byte", 0, 1},
+		{"across chunks", 9998, 3},
+		{"to the end", 5, 0},
+		{"several chunks", 50000, 30000},
+		{"last byte", 99999, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end := tt.offset + tt.length
+			if tt.length == 0 {
+				end = int64(len(data))
+			}
+
+			var buf bytes.Buffer
+			if err := u.ReadRange(context.Background(), &buf, tt.offset, tt.length); err != nil {
+				t.Fatalf("ReadRange() error = %v", err)
+			}
+
+			if !bytes.Equal(buf.Bytes(), data[tt.offset:end]) {
+				t.Fatalf("ReadRange(%d, %d) data differs from the uploaded one", tt.offset, tt.length)
+			}
+		})
+	}
+
+	if err := u.ReadRange(context.Background(), io.Discard, 99999, 2); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("ReadRange() past the end error = %v, want ErrInvalidRange", err)
+	}
+
+	got := downloadTestFile(t, dir, manifestPath, &config.Config{
+		Download: &config.Download{Offset: 20000, Length: 15000},
+	})
+	if !bytes.Equal(got, data[20000:35000]) {
+		t.Fatal("downloaded range differs from the uploaded one")
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(70000)
+
+	// the upload reads standard input
+	stdin, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		w.Write(data)
+		w.Close()
+	}()
+
+	defer func(stdin, stdout *os.File) {
+		os.Stdin, os.Stdout = stdin, stdout
+	}(os.Stdin, os.Stdout)
+	os.Stdin = stdin
+
+	u := newTestUmbra(t, dir, &config.Config{
+		Upload: &config.Upload{InputFilePath: config.Stdio, ChunkSize: 30000, Copies: 1},
+	})
+	if err := u.Upload(context.Background()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// the download writes standard output
+	r, stdout, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = stdout
+
+	done := make(chan []byte)
+	go func() {
+		got, _ := io.ReadAll(r)
+		done <- got
+	}()
+
+	d := newTestUmbra(t, dir, &config.Config{
+		Download: &config.Download{OutputFilePath: config.Stdio},
+	})
+	err = d.Download(context.Background())
+	stdout.Close()
+	got := <-done
+
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	if !bytes.Equal(got, data) {
+		t.Fatal("streamed data differs from the uploaded one")
+	}
+}
+
+func TestDownloadParallel(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(100000)
+
+	manifestPath := uploadTestFile(t, dir, data, &config.Config{
+		Upload: &config.Upload{ChunkSize: 10000, Copies: 1},
+	})
+
+	output := filepath.Join(dir, "output")
+	u := newTestUmbra(t, dir, &config.Config{
+		ManifestPath: manifestPath,
+		Download:     &config.Download{OutputFilePath: output, Parallel: 4},
+	})
+	all, _ := gaugeProviders(u)
+
+	if err := u.Download(context.Background()); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	if all.peak < 2 || all.peak > 4 {
+		t.Fatalf("concurrent downloads = %d, want 2 to 4", all.peak)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("downloaded data differs from the uploaded one, error = %v", err)
+	}
+}
+
+// slowProvider delays its downloads, unless they are cancelled.
+type slowProvider struct {
+	provider.Provider
+	delay time.Duration
+}
+
+func (s *slowProvider) Download(ctx context.Context, meta content.Meta) ([]byte, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return s.Provider.Download(ctx, meta)
+}
+
+func TestDownloadHedge(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(50000)
+
+	manifestPath := uploadTestFile(t, dir, data, &config.Config{
+		Providers: []string{"alpha", "beta"},
+		Upload:    &config.Upload{ChunkSize: 10000, Copies: 2},
+	})
+
+	output := filepath.Join(dir, "output")
+	u := newTestUmbra(t, dir, &config.Config{
+		ManifestPath: manifestPath,
+		Providers:    []string{"alpha", "beta"},
+		Download:     &config.Download{OutputFilePath: output, Hedge: 50 * time.Millisecond},
+	})
+	u.providers[0] = &slowProvider{Provider: u.providers[0], delay: 5 * time.Second}
+
+	start := time.Now()
+	if err := u.Download(context.Background()); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("Download() took %s, want the slow copies to be raced", elapsed)
+	}
+
+	// the cancelled requests to the slow provider tell nothing of its latency
+	_, slow := u.latency.Average("alpha")
+	_, fast := u.latency.Average("beta")
+
+	if slow || !fast {
+		t.Fatalf("observed alpha, beta = %t, %t, want false, true", slow, fast)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("downloaded data differs from the uploaded one, error = %v", err)
+	}
+}
+
+// countingProvider counts its downloads.
+type countingProvider struct {
+	provider.Provider
+	mu sync.Mutex
+	n  int
+}
+
+func (c *countingProvider) Download(ctx context.Context, meta content.Meta) ([]byte, error) {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+
+	return c.Provider.Download(ctx, meta)
+}
+
+func TestDownloadResume(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(100000)
+
+	manifestPath := uploadTestFile(t, dir, data, &config.Config{
+		Providers: []string{"alpha"},
+		Upload:    &config.Upload{ChunkSize: 10000, Copies: 1},
+	})
+
+	// an interrupted download wrote seven chunks and a half, the second one
+	// being corrupted
+	output := filepath.Join(dir, "output")
+	partial := slices.Concat(data[:75000], []byte("garbage"))
+	partial[15000] ^= 0xff
+	if err := os.WriteFile(output+partialSuffix, partial, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	u := newTestUmbra(t, dir, &config.Config{
+		ManifestPath: manifestPath,
+		Providers:    []string{"alpha"},
+		Download:     &config.Download{OutputFilePath: output, Parallel: 2, Resume: true},
+	})
+	counter := &countingProvider{Provider: u.providers[0]}
+	u.providers[0] = counter
+
+	if err := u.Download(context.Background()); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	if counter.n != 4 {
+		t.Fatalf("downloaded chunks = %d, want 4", counter.n)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("downloaded data differs from the uploaded one, error = %v", err)
+	}
+}
+
+func TestDownloadReplacesOutput(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(30000)
+
+	manifestPath := uploadTestFile(t, dir, data, &config.Config{
+		Providers: []string{"alpha"},
+		Upload:    &config.Upload{ChunkSize: 10000, Copies: 1},
+	})
+
+	output := filepath.Join(dir, "output")
+	if err := os.WriteFile(output, []byte("previous"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	download := func(force bool, options map[string]map[string]string) error {
+		u := newTestUmbra(t, dir, &config.Config{
+			ManifestPath:    manifestPath,
+			ProviderOptions: options,
+			Download:        &config.Download{OutputFilePath: output, Force: force},
+		})
+		return u.Download(context.Background())
+	}
+
+	if err := download(false, nil); !errors.Is(err, ErrOutputFileExists) {
+		t.Fatalf("Download() error = %v, want ErrOutputFileExists", err)
+	}
+
+	// a failed download leaves the existing output untouched
+	if err := download(true, map[string]map[string]string{"alpha": {local.OptionDownloadFailureRate: "1"}}); err == nil {
+		t.Fatal("Download() from a failing provider succeeded")
+	}
+
+	if got, _ := os.ReadFile(output); string(got) != "previous" {
+		t.Fatalf("output = %q after a failed download, want it untouched", got)
+	}
+
+	if err := download(true, nil); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	if got, _ := os.ReadFile(output); !bytes.Equal(got, data) {
+		t.Fatal("downloaded data differs from the uploaded one")
+	}
+
+	if _, err := os.Stat(output + partialSuffix); !os.IsNotExist(err) {
+		t.Fatalf("partial file kept after the download, error = %v", err)
+	}
+}
@@ -6,7 +6,22 @@ import "fmt"
 var (
 	ErrInvalidMode                   = fmt.Errorf("either download or upload mode must be specified")
 	ErrUnknownProvider               = fmt.Errorf("unknown provider specified")
+	ErrProviderDisabled              = fmt.Errorf("provider is disabled")
+	ErrInvalidProviderAlias          = fmt.Errorf("provider alias must not shadow a registered provider or paste site")
+	ErrInvalidPasteSite              = fmt.Errorf("paste site must not shadow a registered provider")
 	ErrChunkSizeExceedsProviderLimit = fmt.Errorf("configured chunk size exceeds the maximum allowed by the specified providers")
+	ErrNoProviderAvailable           = fmt.Errorf("no provider available that does not already hold the chunk")
 	ErrCopiesExceedProviders         = fmt.Errorf("number of copies cannot exceed number of available providers")
 	ErrOutputFileHashMismatch        = fmt.Errorf("output file hash does not match expected value")
+	ErrOutputFileExists              = fmt.Errorf("output file already exists, use --force to overwrite it")
+	ErrStreamRequiresChunkSize       = fmt.Errorf("streaming from standard input requires an explicit chunk size")
+	ErrStreamPlan                    = fmt.Errorf("uploads from standard input cannot be planned")
+	ErrStreamResume                  = fmt.Errorf("uploads from standard input cannot be resumed")
+	ErrJournalExists                 = fmt.Errorf("a journal of an interrupted upload exists, run again with --resume or --abandon")
+	ErrJournalInputMismatch          = fmt.Errorf("input file moved or resized since the interrupted upload")
+	ErrInvalidRange                  = fmt.Errorf("requested range is outside of the stored file")
+	ErrChunkHashMismatch             = fmt.Errorf("chunk hash mismatch")
+	ErrInvalidChunkRef               = fmt.Errorf("chunk references an unknown or invalid chunk")
+	ErrChunkUnrecoverable            = fmt.Errorf("no intact copy left of some chunks")
+	ErrMerkleRootMismatch            = fmt.Errorf("chunk hashes do not match the manifest merkle root")
 )
@@ -0,0 +1,25 @@
+package umbra
+
+import (
+	"context"
+	"slices"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/crypto"
+)
+
+// Evacuate moves every chunk copy stored on the configured providers to other
+// providers, keeping the copies of each chunk on distinct providers, and
+// rewrites the manifest with the new copies.
+func (u *Umbra) Evacuate(ctx context.Context) error {
+	return u.rewriteChunks(ctx, "Evacuating: ", u.config.Evacuate.Parallel, u.evacuateChunk, nil)
+}
+
+// evacuateChunk replaces the copies of chunk stored on the evacuated providers.
+func (u *Umbra) evacuateChunk(ctx context.Context, chunk *content.Chunk, crypto *crypto.Crypto, slots providerSlots) chunkRepair {
+	evacuated := func(c content.ChunkCopy) bool {
+		return slices.Contains(u.config.Evacuate.Providers, c.Provider)
+	}
+
+	return u.replaceCopies(ctx, chunk, crypto, slots, evacuated, u.config.Evacuate.Providers)
+}
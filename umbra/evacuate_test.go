@@ -0,0 +1,49 @@
+package umbra
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/henomis/umbra/config"
+)
+
+func TestEvacuate(t *testing.T) {
+	dir := t.TempDir()
+
+	manifestPath := uploadTestFile(t, dir, testData(30000), &config.Config{
+		Providers: []string{"alpha", "beta"},
+		Upload:    &config.Upload{ChunkSize: 10000, Copies: 2},
+	})
+
+	evacuate := func(providers ...string) error {
+		u := newTestUmbra(t, dir, &config.Config{
+			ManifestPath: manifestPath,
+			Providers:    providers,
+			Evacuate:     &config.Evacuate{Providers: []string{"beta"}, Parallel: 2},
+		})
+		return u.Evacuate(context.Background())
+	}
+
+	// alpha already holds every chunk, no provider is left for the copies
+	if err := evacuate("alpha", "beta"); !errors.Is(err, ErrNoProviderAvailable) {
+		t.Fatalf("Evacuate() error = %v, want ErrNoProviderAvailable", err)
+	}
+
+	if err := evacuate(testStores...); err != nil {
+		t.Fatalf("Evacuate() error = %v", err)
+	}
+
+	// the file no longer depends on the evacuated provider
+	clearStore(t, dir, "beta")
+
+	u := newTestUmbra(t, dir, &config.Config{ManifestPath: manifestPath, Verify: &config.Verify{}})
+	report, err := u.Verify(context.Background())
+	if err != nil || report.Status() != Healthy || report.Redundancy() != 2 {
+		t.Fatalf("Verify() = %v, %v, want healthy with 2 copies", report.Status(), err)
+	}
+
+	if report.Providers["beta"] != nil || report.Providers["gamma"].Checked != 3 {
+		t.Fatalf("provider health = %v, want the copies of beta on gamma", report.Providers)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"text/tabwriter"
+	"time"
 
 	"github.com/henomis/umbra/internal/content"
 	"github.com/henomis/umbra/internal/crypto"
@@ -45,6 +46,7 @@ func (u *Umbra) Info(_ context.Context) error {
 }
 
 func printManifest(manifest *manifest.Manifest, content *content.Content) {
+	now := time.Now()
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
 	fmt.Fprintf(w, "Manifest Version:\t%d\n", manifest.Version())
@@ -57,21 +59,75 @@ func printManifest(manifest *manifest.Manifest, content *content.Content) {
 
 	fmt.Fprintf(w, "File size:\t%d bytes\n", content.Size)
 	fmt.Fprintf(w, "File hash:\t%x\n", content.Hash)
-	fmt.Fprintf(w, "Chunks:\t%d\n\n", len(content.Chunks))
+	if content.HasRoot() {
+		fmt.Fprintf(w, "Merkle root:\t%x\n", content.Root)
+	}
+	fmt.Fprintf(w, "Chunks:\t%d\n", len(content.Chunks))
+	fmt.Fprintf(w, "Next expiry:\t%s\n\n", nextExpiry(content, now))
 
 	for i, chunk := range content.Chunks {
 		fmt.Fprintf(w, "Chunk %d:\n", i)
 		fmt.Fprintf(w, "\tSize:\t%d bytes\n", chunk.Size)
 		fmt.Fprintf(w, "\tHash:\t%x\n", chunk.Hash)
+		if chunk.Compression != "" {
+			fmt.Fprintf(w, "\tCompression:\t%s\n", chunk.Compression)
+		}
+		if chunk.Zero {
+			fmt.Fprintf(w, "\tZero:\ttrue\n")
+		}
+		if chunk.Ref != 0 {
+			fmt.Fprintf(w, "\tSame as:\tchunk %d\n", chunk.Ref)
+		}
 
 		fmt.Fprintf(w, "\tCopies:\t%d\n", len(chunk.Copies))
 		for j, copy := range chunk.Copies {
 			fmt.Fprintf(w, "\t\tCopy %d:\n", j)
 			fmt.Fprintf(w, "\t\t\tProvider:\t%s\n", copy.Provider)
 			fmt.Fprintf(w, "\t\t\tMeta:\t%s\n", string(copy.Meta))
+			if !copy.Uploaded.IsZero() {
+				fmt.Fprintf(w, "\t\t\tUploaded:\t%s\n", copy.Uploaded.Format(time.RFC3339))
+			}
+			fmt.Fprintf(w, "\t\t\tExpires in:\t%s\n", ttl(copy, now))
 		}
 		fmt.Fprintln(w)
 	}
 
 	w.Flush()
 }
+
+// ttl describes the time left before the provider may drop the copy.
+func ttl(c content.ChunkCopy, now time.Time) string {
+	switch {
+	case c.Uploaded.IsZero():
+		return "unknown"
+	case c.Expires.IsZero():
+		return "never"
+	case !c.Expires.After(now):
+		return "expired"
+	default:
+		return c.Expires.Sub(now).Truncate(time.Minute).String()
+	}
+}
+
+// nextExpiry describes the time left before the first copy of the stored
+// content expires.
+func nextExpiry(stored *content.Content, now time.Time) string {
+	var next *content.ChunkCopy
+	for i := range stored.Chunks {
+		for j := range stored.Chunks[i].Copies {
+			c := &stored.Chunks[i].Copies[j]
+			if c.Uploaded.IsZero() {
+				return "unknown"
+			}
+			if !c.Expires.IsZero() && (next == nil || c.Expires.Before(next.Expires)) {
+				next = c
+			}
+		}
+	}
+
+	if next == nil {
+		return "never"
+	}
+
+	return ttl(*next, now)
+}
@@ -0,0 +1,248 @@
+package umbra
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/crypto"
+	"github.com/henomis/umbra/internal/manifest"
+)
+
+// journalSuffix is appended to the manifest or input path to name the journal.
+const journalSuffix = ".journal"
+
+// journal records the progress of an upload in an encrypted file, so that an
+// interrupted upload can be resumed and its orphaned copies listed.
+type journal struct {
+	mu     sync.Mutex
+	path   string
+	crypto *crypto.Crypto
+	state  journalState
+}
+
+// journalState is the journal content encrypted on disk.
+type journalState struct {
+	Input     journalInput          `json:"input"`
+	ChunkSize int64                 `json:"chunk_size"`
+	Compress  string                `json:"compress,omitempty"`
+	Crypto    crypto.Parameters     `json:"crypto"`
+	Chunks    map[int]*journalChunk `json:"chunks"`
+}
+
+// journalInput identifies the input file of the upload without reading it.
+// The input is not hashed again on resume: chunks changed in place are caught
+// by their hash, and uploaded again.
+type journalInput struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// journalChunk holds the copies uploaded so far for the chunk read at a given
+// index of the input.
+type journalChunk struct {
+	Hash   [32]byte            `json:"hash"`
+	Codec  string              `json:"codec,omitempty"`
+	Copies []content.ChunkCopy `json:"copies"`
+}
+
+// journalPath returns the configured journal path, or derives it from the
+// manifest path, or from the input path when the manifest is uploaded to a
+// provider.
+func (u *Umbra) journalPath() string {
+	if u.config.Upload.JournalPath != "" {
+		return u.config.Upload.JournalPath
+	}
+
+	if strings.HasPrefix(u.config.ManifestPath, "provider:") {
+		return u.config.Upload.InputFilePath + journalSuffix
+	}
+
+	return u.config.ManifestPath + journalSuffix
+}
+
+// statInput returns the identity of the input file at path.
+func statInput(path string) (journalInput, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return journalInput{}, err
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return journalInput{}, err
+	}
+
+	return journalInput{
+		Path:    absPath,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UTC(),
+	}, nil
+}
+
+// matches reports whether i and other identify the same file, of the same
+// size and so split into the same chunks.
+func (i journalInput) matches(other journalInput) bool {
+	return i.Path == other.Path && i.Size == other.Size
+}
+
+// modified reports whether the file was modified, or only touched, since
+// other was recorded.
+func (i journalInput) modified(other journalInput) bool {
+	return !i.ModTime.Equal(other.ModTime)
+}
+
+// newJournal creates a journal encrypted with c, whose key is derived once and
+// reused by every save.
+func newJournal(path string, c *crypto.Crypto, state journalState) *journal {
+	if state.Chunks == nil {
+		state.Chunks = make(map[int]*journalChunk)
+	}
+
+	return &journal{
+		path:   path,
+		crypto: c,
+		state:  state,
+	}
+}
+
+// loadJournal reads and decrypts the journal at path.
+func loadJournal(path string, password []byte) (*journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the salt of the journal is kept, so that its key is not derived again
+	c, err := crypto.New(password)
+	if err != nil {
+		return nil, err
+	}
+
+	stateData, err := manifest.New(c).Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var state journalState
+	if err := json.Unmarshal(stateData, &state); err != nil {
+		return nil, err
+	}
+
+	return newJournal(path, c, state), nil
+}
+
+// lookup returns the copies recorded for the chunk read at index, provided
+// its hash did not change. A nil journal records nothing.
+func (j *journal) lookup(index int, hash [32]byte) (*journalChunk, bool) {
+	if j == nil {
+		return nil, false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	chunk, ok := j.state.Chunks[index]
+	if !ok || chunk.Hash != hash {
+		return nil, false
+	}
+
+	return &journalChunk{
+		Hash:   chunk.Hash,
+		Codec:  chunk.Codec,
+		Copies: slices.Clone(chunk.Copies),
+	}, true
+}
+
+// record adds a successfully uploaded copy of the chunk read at index and
+// persists the journal. Recording into a nil journal is a no-op.
+func (j *journal) record(index int, hash [32]byte, codec string, c content.ChunkCopy) error {
+	if j == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	chunk, ok := j.state.Chunks[index]
+	if !ok || chunk.Hash != hash {
+		chunk = &journalChunk{Hash: hash}
+		j.state.Chunks[index] = chunk
+	}
+
+	chunk.Codec = codec
+	chunk.Copies = append(chunk.Copies, c)
+
+	return j.save()
+}
+
+// copies returns every copy recorded in the journal, ordered by chunk index.
+func (j *journal) copies() []content.ChunkCopy {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	indexes := make([]int, 0, len(j.state.Chunks))
+	for index := range j.state.Chunks {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+
+	copies := make([]content.ChunkCopy, 0)
+	for _, index := range indexes {
+		copies = append(copies, j.state.Chunks[index].Copies...)
+	}
+
+	return copies
+}
+
+// save encrypts the journal with a fresh nonce and atomically replaces the
+// journal file. The caller must hold j.mu.
+func (j *journal) save() error {
+	stateData, err := json.Marshal(j.state)
+	if err != nil {
+		return err
+	}
+
+	if err := j.crypto.RenewNonce(); err != nil {
+		return err
+	}
+
+	data := bytes.NewBuffer(nil)
+	if err := manifest.New(j.crypto).Encode(data, stateData); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	return nil
+}
+
+// remove deletes the journal file.
+func (j *journal) remove() error {
+	return os.Remove(j.path)
+}
@@ -0,0 +1,41 @@
+package umbra
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/selection"
+)
+
+// latencyTracker keeps a moving average of the download latency observed for
+// each provider. The averages are kept in memory for the current run only,
+// each run measuring the providers again.
+type latencyTracker struct {
+	*selection.MovingAverages
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		MovingAverages: selection.NewMovingAverages(),
+	}
+}
+
+// order returns the copies sorted by observed provider latency, fastest first.
+// Providers not observed yet come first, so that they get measured, and keep
+// their manifest order.
+func (l *latencyTracker) order(copies []content.ChunkCopy) []content.ChunkCopy {
+	// averages are read once, since downloads in progress update them
+	averages := make(map[string]time.Duration, len(copies))
+	for _, c := range copies {
+		averages[c.Provider], _ = l.Average(c.Provider)
+	}
+
+	ordered := slices.Clone(copies)
+	slices.SortStableFunc(ordered, func(a, b content.ChunkCopy) int {
+		return cmp.Compare(averages[a.Provider], averages[b.Provider])
+	})
+
+	return ordered
+}
@@ -0,0 +1,245 @@
+package umbra
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/henomis/umbra/internal/compress"
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/crypto"
+	"github.com/henomis/umbra/internal/ghost"
+	"github.com/henomis/umbra/internal/manifest"
+	"github.com/henomis/umbra/internal/provider"
+	"github.com/henomis/umbra/internal/selection"
+)
+
+// placeholderMeta stands for the metadata returned by a provider when
+// estimating the manifest size, since the real one is only known once the
+// copy is uploaded.
+var placeholderMeta = content.Meta(`{"url":"https://paste.example.com/xxxxxxxxxxxxxxxx"}`)
+
+// UploadPlan describes what an upload would do, computed without reading the
+// input or contacting any provider.
+type UploadPlan struct {
+	ChunkSize int64 `json:"chunk_size"`
+	// ChunkLimit is the size of the largest chunk payload every provider
+	// accepts.
+	ChunkLimit int64                    `json:"chunk_limit"`
+	Chunks     []*PlannedChunk          `json:"chunks"`
+	Providers  map[string]*ProviderPlan `json:"providers"`
+	// ManifestSize is the estimated size of the manifest, once encoded with
+	// the ghost mode.
+	ManifestSize int64  `json:"manifest_size"`
+	GhostMode    string `json:"ghost_mode,omitempty"`
+	// ManifestFits reports whether the manifest fits the ghost mode and the
+	// provider it is uploaded to.
+	ManifestFits bool `json:"manifest_fits"`
+	// EarliestExpiry is when the first copy may be dropped, zero if no copy
+	// expires.
+	EarliestExpiry time.Time `json:"earliest_expiry,omitzero"`
+}
+
+// PlannedChunk describes a chunk and the providers receiving its copies.
+type PlannedChunk struct {
+	Index int   `json:"index"`
+	Size  int64 `json:"size"`
+	// StoredSize is the size of each copy once compressed and encrypted, an
+	// upper bound when compressing.
+	StoredSize int64    `json:"stored_size"`
+	Providers  []string `json:"providers"`
+}
+
+// ProviderPlan holds the copies a provider would receive.
+type ProviderPlan struct {
+	Copies int `json:"copies"`
+	// Bytes is the amount of data sent to the provider, after its encoding.
+	Bytes int64 `json:"bytes"`
+}
+
+// Plan computes the chunk sizes, the placement of every copy and the manifest
+// size of the configured upload without touching the network. All-zero and
+// repeated chunks are planned as regular ones, since the input is not read.
+func (u *Umbra) Plan(_ context.Context) (*UploadPlan, error) {
+	if u.config.Upload.IsStream() {
+		return nil, ErrStreamPlan
+	}
+
+	chunkSize, chunks, err := u.calculateChunkSize()
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate chunk size: %w", err)
+	}
+
+	fileInfo, err := os.Stat(u.config.Upload.InputFilePath)
+	if err != nil {
+		return nil, err
+	}
+	fileSize := fileInfo.Size()
+
+	plan := &UploadPlan{
+		ChunkSize:  chunkSize,
+		ChunkLimit: u.getMaxChunkSizeForProviders(),
+		Chunks:     make([]*PlannedChunk, 0, chunks),
+		Providers:  make(map[string]*ProviderPlan),
+		GhostMode:  u.config.GhostMode,
+	}
+
+	// same check as the upload
+	if err := u.checkChunkSize(chunkSize); err != nil {
+		return nil, err
+	}
+
+	// copies are placed through a policy of their own, so that planning does
+	// not advance the state of the configured one
+	policy, err := selection.New(u.config.Selection, u.config.Providers, u.config.ProviderWeights)
+	if err != nil {
+		return nil, err
+	}
+
+	// the manifest is estimated from a content holding placeholder copies
+	now := time.Now()
+	stored := content.New([32]byte{}, fileSize)
+	var earliest time.Duration
+
+	for i := range chunks {
+		size := min(chunkSize, fileSize-i*chunkSize)
+		chunk := &PlannedChunk{
+			Index:      int(i),
+			Size:       size,
+			StoredSize: compress.Bound(u.config.Upload.Compress, size) + crypto.Overhead,
+		}
+
+		var chunkID *uint32
+		for len(chunk.Providers) < u.config.Upload.Copies {
+			p, err := u.selectProviderWith(policy, chunk.StoredSize, chunk.Providers, nil)
+			if err != nil {
+				return nil, err
+			}
+			chunk.Providers = append(chunk.Providers, p.Name())
+
+			placed, ok := plan.Providers[p.Name()]
+			if !ok {
+				placed = &ProviderPlan{}
+				plan.Providers[p.Name()] = placed
+			}
+			placed.Copies++
+			placed.Bytes += provider.EncodedSize(p, chunk.StoredSize)
+
+			if expire := p.Expire(); expire > 0 && (earliest == 0 || expire < earliest) {
+				earliest = expire
+			}
+
+			c := content.NewChunkCopy(p.Name(), placeholderMeta, now, p.Expire())
+			id := stored.Add([32]byte{}, size, u.config.Upload.Compress, chunkID, c)
+			chunkID = &id
+		}
+
+		plan.Chunks = append(plan.Chunks, chunk)
+	}
+
+	if earliest > 0 {
+		plan.EarliestExpiry = now.UTC().Truncate(time.Second).Add(earliest)
+	}
+
+	if err := u.planManifest(plan, stored); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// planManifest encodes the content as the upload would and records its size
+// and whether it fits the ghost mode and the provider receiving it.
+func (u *Umbra) planManifest(plan *UploadPlan, stored *content.Content) error {
+	stored.Root = stored.ComputeRoot()
+
+	contentData, err := stored.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode content: %w", err)
+	}
+
+	crypto, err := crypto.New([]byte(u.config.Password))
+	if err != nil {
+		return fmt.Errorf("failed to create crypto: %w", err)
+	}
+
+	manifestData := bytes.NewBuffer(nil)
+	if err := manifest.New(crypto).Encode(manifestData, contentData); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	plan.ManifestSize = int64(manifestData.Len())
+
+	encoded, err := u.encodeGhost(manifestData.Bytes())
+	if errors.Is(err, ghost.ErrCapacityExceeded) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode ghost: %w", err)
+	}
+
+	plan.ManifestSize = int64(len(encoded))
+	plan.ManifestFits = true
+
+	if name, ok := strings.CutPrefix(u.config.ManifestPath, "provider:"); ok {
+		name, _, _ = strings.Cut(name, ":")
+		p, err := u.getProviderByName(name)
+		if err != nil {
+			return err
+		}
+		plan.ManifestFits = plan.ManifestSize <= provider.Capacity(p)
+	}
+
+	return nil
+}
+
+func (u *Umbra) printUploadPlan(plan *UploadPlan) error {
+	if u.config.Upload.JSON {
+		encoder := json.NewEncoder(u.output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	}
+
+	w := tabwriter.NewWriter(u.output, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Chunk size:\t%d bytes\n", plan.ChunkSize)
+	fmt.Fprintf(w, "Chunk limit:\t%d bytes\n", plan.ChunkLimit)
+	fmt.Fprintf(w, "Chunks:\t%d\n", len(plan.Chunks))
+	for _, chunk := range plan.Chunks {
+		fmt.Fprintf(w, "\tChunk %d:\t%d bytes\t%d stored\t%s\n", chunk.Index, chunk.Size, chunk.StoredSize, strings.Join(chunk.Providers, ", "))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "Providers:\n")
+	for _, name := range slices.Sorted(maps.Keys(plan.Providers)) {
+		placed := plan.Providers[name]
+		fmt.Fprintf(w, "\t%s:\t%d copies\t%d bytes\n", name, placed.Copies, placed.Bytes)
+	}
+	fmt.Fprintln(w)
+
+	fits := "fits"
+	if !plan.ManifestFits {
+		fits = "does not fit"
+	}
+	ghostMode := plan.GhostMode
+	if ghostMode == "" {
+		ghostMode = "none"
+	}
+	fmt.Fprintf(w, "Manifest size:\t%d bytes (estimated, ghost mode %s: %s)\n", plan.ManifestSize, ghostMode, fits)
+
+	if plan.EarliestExpiry.IsZero() {
+		fmt.Fprintf(w, "Earliest expiry:\tnever\n")
+	} else {
+		fmt.Fprintf(w, "Earliest expiry:\t%s\n", plan.EarliestExpiry.Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,100 @@
+package umbra
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/henomis/umbra/config"
+	"github.com/henomis/umbra/internal/ghost"
+	"github.com/henomis/umbra/internal/selection"
+)
+
+func TestPlanPlacement(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(40000)
+
+	input := filepath.Join(dir, "input")
+	if err := os.WriteFile(input, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Providers: testStores,
+		Selection: selection.RoundRobin,
+		Upload: &config.Upload{
+			InputFilePath: input,
+			Chunks:        4,
+			Copies:        1,
+			Parallel:      1,
+		},
+	}
+	u := newTestUmbra(t, dir, cfg)
+
+	plan, err := u.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(plan.Chunks) != 4 {
+		t.Fatalf("planned chunks = %d, want 4", len(plan.Chunks))
+	}
+
+	// planning leaves the policy untouched, so the upload places the copies
+	// as planned
+	if err := u.Upload(context.Background()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	c := loadTestContent(t, dir, cfg.ManifestPath)
+	for i, chunk := range c.Chunks {
+		var got []string
+		for _, copy := range chunk.Copies {
+			got = append(got, copy.Provider)
+		}
+		if want := plan.Chunks[i].Providers; !slices.Equal(got, want) {
+			t.Fatalf("chunk %d providers = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestPlanManifestFits(t *testing.T) {
+	tests := []struct {
+		name      string
+		ghostMode string
+		want      bool
+	}{
+		{"no ghost", "", true},
+		{"image", ghost.Image, true},
+		{"qrcode over capacity", ghost.QRCode, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			input := filepath.Join(dir, "input")
+			if err := os.WriteFile(input, testData(100000), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			u := newTestUmbra(t, dir, &config.Config{
+				GhostMode: tt.ghostMode,
+				Upload: &config.Upload{
+					InputFilePath: input,
+					Chunks:        200,
+					Copies:        2,
+				},
+			})
+
+			plan, err := u.Plan(context.Background())
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			if plan.ManifestFits != tt.want {
+				t.Fatalf("ManifestFits = %v, want %v", plan.ManifestFits, tt.want)
+			}
+		})
+	}
+}
@@ -1,98 +1,200 @@
 package umbra
 
 import (
-	"crypto/rand"
-	"math/big"
+	"fmt"
+	"maps"
+	"slices"
 	"time"
 
+	"github.com/henomis/umbra/internal/crypto"
 	"github.com/henomis/umbra/internal/provider"
-	"github.com/henomis/umbra/internal/provider/clbin"
-	"github.com/henomis/umbra/internal/provider/pastecnetorg"
-	"github.com/henomis/umbra/internal/provider/pipfi"
-	"github.com/henomis/umbra/internal/provider/termbin"
+	"github.com/henomis/umbra/internal/provider/paste"
+	"github.com/henomis/umbra/internal/provider/plugin"
+	"github.com/henomis/umbra/internal/selection"
+
+	// built-in providers register themselves
+	_ "github.com/henomis/umbra/internal/provider/clbin"
+	_ "github.com/henomis/umbra/internal/provider/local"
+	_ "github.com/henomis/umbra/internal/provider/pastecnetorg"
+	_ "github.com/henomis/umbra/internal/provider/pipfi"
+	_ "github.com/henomis/umbra/internal/provider/s3"
+	_ "github.com/henomis/umbra/internal/provider/termbin"
 )
 
-// buildProviders initializes the configured providers list, applying defaults
-// when none are specified and returning an error for unknown providers.
+// buildProviders initializes the configured providers list from the provider
+// registry, holding the built-in providers and the discovered plugins, and
+// from the declared paste sites, applying the registered defaults, the paste
+// sites and the aliases when none are specified. Aliases create a provider
+// recording its copies under the alias name. It returns an error for unknown
+// or disabled providers.
 func (u *Umbra) buildProviders() error {
-	var providers []provider.Provider
+	plugin.Discover()
 
+	for name := range u.config.PasteSites {
+		if _, ok := provider.Lookup(name); ok {
+			return fmt.Errorf("%w: %s", ErrInvalidPasteSite, name)
+		}
+	}
+
+	for alias := range u.config.ProviderAliases {
+		if u.isProvider(alias) {
+			return fmt.Errorf("%w: %s", ErrInvalidProviderAlias, alias)
+		}
+	}
+
+	for name := range u.config.ProviderOptions {
+		if !u.isProvider(name) && u.config.ProviderAliases[name] == "" {
+			return fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+		}
+	}
+
+	// paste sites and aliases are available along with the defaults
 	if len(u.config.Providers) == 0 {
-		u.config.Providers = provider.DefaultProviders
-	}
-
-	for _, p := range u.config.Providers {
-		switch p {
-		case provider.TERMBIN:
-			providers = append(providers, termbin.New())
-		case provider.CLBIN:
-			providers = append(providers, clbin.New())
-		case provider.PIPFI:
-			providers = append(providers, pipfi.New())
-		case provider.PASTECNETORG:
-			providers = append(providers, pastecnetorg.New())
-		default:
-			return ErrUnknownProvider
+		names := slices.Concat(provider.Defaults(), slices.Sorted(maps.Keys(u.config.PasteSites)), slices.Sorted(maps.Keys(u.config.ProviderAliases)))
+		u.config.Providers = slices.DeleteFunc(names, func(name string) bool {
+			return slices.Contains(u.config.DisabledProviders, name) || slices.Contains(u.config.DisabledProviders, u.config.ProviderAliases[name])
+		})
+	}
+
+	for _, name := range u.config.Providers {
+		p, err := u.newProvider(name)
+		if err != nil {
+			return err
 		}
+
+		// kept as soon as created, to be closed if a later one fails
+		u.providers = append(u.providers, p)
 	}
 
-	u.providers = providers
 	return nil
 }
 
-// getMaxChunkSizeForProviders returns the smallest MaxSize value among the configured providers.
-// It is used to ensure data chunks respect the most restrictive provider limit.
-func (u *Umbra) getMaxChunkSizeForProviders() int64 {
-	var maxSize int64 = -1
+// isProvider reports whether name is a registered provider or a paste site.
+func (u *Umbra) isProvider(name string) bool {
+	_, registered := provider.Lookup(name)
+	_, declared := u.config.PasteSites[name]
+	return registered || declared
+}
 
-	for _, p := range u.providers {
-		pMax := p.MaxSize()
-		if maxSize < 0 || pMax < maxSize {
-			maxSize = pMax
-		}
+// isConfigured reports whether name is one of the configured providers or an
+// alias.
+func (u *Umbra) isConfigured(name string) bool {
+	if _, ok := u.config.ProviderAliases[name]; ok {
+		return true
 	}
 
-	return maxSize
+	return slices.ContainsFunc(u.providers, func(p provider.Provider) bool {
+		return p.Name() == name
+	})
 }
 
-// getRandomProvider selects and returns a random provider from the configured set.
-// It returns ErrUnknownProvider when no providers are available, and falls back to
-// the first provider if randomness cannot be obtained.
-func (u *Umbra) getRandomProvider() (provider.Provider, error) {
-	if len(u.providers) == 0 {
-		return nil, ErrUnknownProvider
+// newProvider creates the named registered provider, paste site or alias with
+// its options. It returns an error for unknown or disabled providers.
+func (u *Umbra) newProvider(name string) (provider.Provider, error) {
+	registered := name
+	if target, ok := u.config.ProviderAliases[name]; ok {
+		registered = target
+	}
+
+	if slices.Contains(u.config.DisabledProviders, name) || slices.Contains(u.config.DisabledProviders, registered) {
+		return nil, fmt.Errorf("%w: %s", ErrProviderDisabled, name)
 	}
 
-	providerCount := big.NewInt(int64(len(u.providers)))
-	idx, err := rand.Int(rand.Reader, providerCount)
+	var factory provider.Factory
+	if spec, ok := u.config.PasteSites[registered]; ok {
+		factory = func(options provider.Options) (provider.Provider, error) {
+			return paste.New(registered, spec, options)
+		}
+	} else {
+		registration, ok := provider.Lookup(registered)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+		}
+		factory = registration.Factory
+	}
+
+	// alias options override the ones of the registered provider
+	options := maps.Clone(u.config.ProviderOptions[registered])
+	if name != registered {
+		options = mergeOptions(options, u.config.ProviderOptions[name])
+	}
+
+	p, err := factory(options)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create provider %s: %w", name, err)
+	}
+
+	if name != registered {
+		p = provider.Rename(p, name)
 	}
 
-	return u.providers[idx.Int64()], nil
+	return p, nil
 }
 
-func (u *Umbra) getUniqueRadomProvider(providers []provider.Provider) (provider.Provider, error) {
-	for {
-		p, err := u.getRandomProvider()
-		if err != nil {
-			return nil, err
+// mergeOptions returns options with the values of override added.
+func mergeOptions(options, override map[string]string) map[string]string {
+	if options == nil {
+		options = make(map[string]string)
+	}
+	maps.Copy(options, override)
+	return options
+}
+
+// getMaxChunkSizeForProviders returns the size of the largest chunk payload,
+// before encryption, that every configured provider accepts. It accounts for
+// the AEAD overhead and for the encoding applied by the providers, so that
+// data chunks respect the most restrictive provider limit.
+func (u *Umbra) getMaxChunkSizeForProviders() int64 {
+	var maxSize int64 = -1
+
+	for _, p := range u.providers {
+		pMax := provider.Capacity(p) - crypto.Overhead
+		if maxSize < 0 || pMax < maxSize {
+			maxSize = pMax
 		}
+	}
+
+	return max(maxSize, 0)
+}
+
+// selectProvider selects, through the configured selection policy, a provider
+// to store a copy of size bytes of a chunk held by holders. Providers holding
+// the chunk, excluded ones and ones sharing a distinct tag value with a holder
+// are not candidates. It returns ErrNoProviderAvailable when no candidate is
+// left.
+func (u *Umbra) selectProvider(size int64, holders, excluded []string) (provider.Provider, error) {
+	return u.selectProviderWith(u.selection, size, holders, excluded)
+}
+
+// selectProviderWith is selectProvider through the given policy, so that the
+// state of the configured one is left untouched when only planning.
+func (u *Umbra) selectProviderWith(policy selection.Policy, size int64, holders, excluded []string) (provider.Provider, error) {
+	tags := selection.Tags(u.config.ProviderTags)
 
-		unique := true
-		for _, existing := range providers {
-			if existing.Name() == p.Name() {
-				unique = false
-				break
-			}
+	candidates := make([]provider.Provider, 0, len(u.providers))
+	for _, p := range u.providers {
+		name := p.Name()
+		if slices.Contains(holders, name) || slices.Contains(excluded, name) {
+			continue
 		}
 
-		if unique {
-			return p, nil
+		if !tags.Distinct(name, holders, u.config.DistinctTags) {
+			continue
 		}
+
+		candidates = append(candidates, p)
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoProviderAvailable
 	}
+
+	return policy.Select(candidates, size), nil
 }
 
+// getProviderByName returns the named provider. Registered providers that are
+// not configured, such as plugins not used by default, are created when first
+// needed, so that the copies they store can be read.
 func (u *Umbra) getProviderByName(name string) (provider.Provider, error) {
 	for _, p := range u.providers {
 		if p.Name() == name {
@@ -100,7 +202,20 @@ func (u *Umbra) getProviderByName(name string) (provider.Provider, error) {
 		}
 	}
 
-	return nil, ErrUnknownProvider
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if p, ok := u.unlisted[name]; ok {
+		return p, nil
+	}
+
+	p, err := u.newProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	u.unlisted[name] = p
+
+	return p, nil
 }
 
 func (u *Umbra) getProviderMinExpireDuration() time.Duration {
@@ -0,0 +1,53 @@
+package umbra
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/crypto"
+)
+
+// Refresh uploads again the chunk copies expiring within the configured time,
+// and rewrites the manifest with the new copies in place of the expiring ones.
+// Copies recorded without their upload time are refreshed as well, since
+// their expiry is unknown. A manifest stored by a provider is uploaded again
+// when it expires within the configured time too.
+func (u *Umbra) Refresh(ctx context.Context) error {
+	return u.rewriteChunks(ctx, "Refreshing: ", u.config.Refresh.Parallel, u.refreshChunk, u.manifestExpiring)
+}
+
+// manifestExpiring reports whether the provider storing the manifest of
+// stored may drop it within the configured time. Manifests saved to a file
+// never expire, and the ones saved before their save time was recorded are
+// assumed to.
+func (u *Umbra) manifestExpiring(stored *content.Content) (bool, error) {
+	name, ok := strings.CutPrefix(u.config.ManifestPath, "provider:")
+	if !ok {
+		return false, nil
+	}
+	name, _, _ = strings.Cut(name, ":")
+
+	p, err := u.getProviderByName(name)
+	if err != nil {
+		return false, err
+	}
+
+	// the manifest expires like a copy uploaded when it was saved
+	manifestCopy := content.NewChunkCopy(name, nil, stored.Saved, p.Expire())
+
+	return manifestCopy.ExpiresBefore(time.Now().Add(u.config.Refresh.Before)), nil
+}
+
+// refreshChunk replaces the copies of chunk expiring within the configured
+// time.
+func (u *Umbra) refreshChunk(ctx context.Context, chunk *content.Chunk, crypto *crypto.Crypto, slots providerSlots) chunkRepair {
+	deadline := time.Now().Add(u.config.Refresh.Before)
+
+	expiring := func(c content.ChunkCopy) bool {
+		return c.ExpiresBefore(deadline)
+	}
+
+	return u.replaceCopies(ctx, chunk, crypto, slots, expiring, nil)
+}
@@ -0,0 +1,121 @@
+package umbra
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/henomis/umbra/config"
+	"github.com/henomis/umbra/internal/provider/local"
+)
+
+func TestRefresh(t *testing.T) {
+	dir := t.TempDir()
+
+	expiring := map[string]map[string]string{
+		"alpha": {local.OptionExpire: "1h"},
+		"beta":  {local.OptionExpire: "1h"},
+		"gamma": {local.OptionExpire: "1h"},
+	}
+
+	manifestPath := uploadTestFile(t, dir, testData(30000), &config.Config{
+		Providers:       []string{"alpha", "beta"},
+		ProviderOptions: expiring,
+		Upload:          &config.Upload{ChunkSize: 10000, Copies: 2},
+	})
+
+	// copiesOf returns the stores of the copies recorded in the manifest
+	copiesOf := func() map[string]int {
+		stores := make(map[string]int)
+		for _, chunk := range loadTestContent(t, dir, manifestPath).Chunks {
+			for _, c := range chunk.Copies {
+				if c.Expires.Sub(c.Uploaded) != time.Hour {
+					t.Fatalf("copy uploaded at %s expires at %s, want an hour later", c.Uploaded, c.Expires)
+				}
+				stores[c.Provider]++
+			}
+		}
+		return stores
+	}
+
+	refresh := func(before time.Duration, providers ...string) {
+		u := newTestUmbra(t, dir, &config.Config{
+			ManifestPath:    manifestPath,
+			Providers:       providers,
+			ProviderOptions: expiring,
+			Refresh:         &config.Refresh{Before: before, Parallel: 2},
+		})
+		if err := u.Refresh(context.Background()); err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+	}
+
+	if got := copiesOf(); got["alpha"] != 3 || got["beta"] != 3 {
+		t.Fatalf("copies = %v, want 3 on alpha and beta", got)
+	}
+
+	// copies expiring later are left alone
+	refresh(30*time.Minute, testStores...)
+	if stored := len(storedCopies(t, dir, "alpha")) + len(storedCopies(t, dir, "beta")); stored != 6 {
+		t.Fatalf("stored copies = %d, want 6", stored)
+	}
+
+	// the expiring copies are replaced on the given providers
+	refresh(2*time.Hour, "alpha", "gamma")
+	if got := copiesOf(); got["alpha"] != 3 || got["gamma"] != 3 || got["beta"] != 0 {
+		t.Fatalf("copies = %v, want 3 on alpha and gamma", got)
+	}
+
+	if stored := len(storedCopies(t, dir, "beta")); stored != 3 {
+		t.Fatalf("copies stored by beta = %d, want 3", stored)
+	}
+}
+
+func TestRefreshManifest(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(30000)
+
+	// the chunks are kept by beta, the manifest by alpha for an hour
+	options := map[string]map[string]string{
+		"alpha": {local.OptionExpire: "1h"},
+	}
+
+	manifestPath := uploadTestFile(t, dir, data, &config.Config{
+		ManifestPath:    "provider:alpha",
+		Providers:       []string{"beta"},
+		ProviderOptions: options,
+		Upload:          &config.Upload{ChunkSize: 10000, Copies: 1},
+	})
+
+	refresh := func(before time.Duration) string {
+		u := newTestUmbra(t, dir, &config.Config{
+			ManifestPath:    manifestPath,
+			Providers:       []string{"beta"},
+			ProviderOptions: options,
+			Refresh:         &config.Refresh{Before: before},
+		})
+		if err := u.Refresh(context.Background()); err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+		return u.config.ManifestPath
+	}
+
+	// a manifest expiring later is left alone
+	if got := refresh(30 * time.Minute); got != manifestPath || len(storedCopies(t, dir, "alpha")) != 1 {
+		t.Fatalf("manifest path = %s, want %s unchanged", got, manifestPath)
+	}
+
+	// an expiring manifest is uploaded again, along with no chunk copy
+	refreshed := refresh(2 * time.Hour)
+	if refreshed == manifestPath || len(storedCopies(t, dir, "alpha")) != 2 {
+		t.Fatalf("manifest path = %s, want a new copy on alpha", refreshed)
+	}
+	if got := len(storedCopies(t, dir, "beta")); got != 3 {
+		t.Fatalf("copies stored by beta = %d, want 3", got)
+	}
+
+	if got := downloadTestFile(t, dir, refreshed, &config.Config{}); !bytes.Equal(got, data) {
+		t.Fatal("downloaded data differs from the uploaded one")
+	}
+}
@@ -0,0 +1,263 @@
+package umbra
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/crypto"
+)
+
+// chunkRewriter updates the copies of a stored chunk in place.
+type chunkRewriter func(ctx context.Context, chunk *content.Chunk, crypto *crypto.Crypto, slots providerSlots) chunkRepair
+
+// chunkRepair is the outcome of rewriting the copies of a single chunk.
+type chunkRepair struct {
+	dropped  int
+	uploaded int
+	lost     bool
+	err      error
+}
+
+// Repair verifies every copy of the stored chunks, and uploads the data of an
+// intact copy to providers not holding the chunk until each chunk has the
+// configured number of copies. Copies that could not be read, possibly for a
+// temporary reason, are dropped only once new copies take their place. The
+// updated manifest replaces the original one. Chunks without any intact copy
+// left keep their copies and are reported as unrecoverable.
+func (u *Umbra) Repair(ctx context.Context) error {
+	return u.rewriteChunks(ctx, "Repairing: ", u.config.Repair.Parallel, u.repairChunk, nil)
+}
+
+// rewriteChunks applies fn to the stored chunks by a pool of parallel workers,
+// and saves the manifest when copies were dropped or uploaded, or when resave,
+// if not nil, reports that the manifest must be saved again anyway.
+func (u *Umbra) rewriteChunks(ctx context.Context, name string, parallel int, fn chunkRewriter, resave func(*content.Content) (bool, error)) error {
+	stored, crypto, err := u.loadContent(ctx)
+	if err != nil {
+		return err
+	}
+
+	save := false
+	if resave != nil {
+		if save, err = resave(stored); err != nil {
+			return err
+		}
+	}
+
+	// only chunks holding their own data are stored
+	var chunks []*content.Chunk
+	for i := range stored.Chunks {
+		if len(stored.Chunks[i].Copies) > 0 {
+			chunks = append(chunks, &stored.Chunks[i])
+		}
+	}
+
+	bar := u.newProgressBar(name, int64(len(chunks)))
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, max(parallel, 1))
+	slots := u.newProviderSlots()
+
+	repairs := make([]chunkRepair, len(chunks))
+
+	for i, chunk := range chunks {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Go(func() {
+			defer func() { <-workers }()
+
+			repairs[i] = fn(ctx, chunk, crypto, slots)
+
+			if bar != nil {
+				bar.Increment()
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		if bar != nil {
+			bar.Abort(false)
+			bar.Wait()
+		}
+		return err
+	}
+
+	if bar != nil {
+		bar.Wait()
+	}
+
+	var dropped, uploaded, lost, failed int
+	var repairErr error
+	for _, repair := range repairs {
+		dropped += repair.dropped
+		uploaded += repair.uploaded
+		if repair.lost {
+			lost++
+		}
+		if repair.err != nil {
+			failed++
+			repairErr = repair.err
+		}
+	}
+
+	// the manifest is rewritten only when copies changed, keeping the copies
+	// uploaded so far even when some chunks could not be rewritten
+	if save || dropped > 0 || uploaded > 0 {
+		if err := u.saveContent(ctx, stored, crypto); err != nil {
+			return fmt.Errorf("failed to save manifest: %w", err)
+		}
+	}
+
+	if !u.config.Quiet {
+		fmt.Fprintf(u.output, "🔧 Copies dropped: %d, copies uploaded: %d\n", dropped, uploaded)
+	}
+
+	if lost > 0 {
+		return fmt.Errorf("%w: %d chunks", ErrChunkUnrecoverable, lost)
+	}
+
+	if repairErr != nil {
+		return fmt.Errorf("failed to upload the copies of %d chunks: %w", failed, repairErr)
+	}
+
+	if !u.config.Quiet {
+		fmt.Fprintf(u.output, "✅ Manifest up to date: '%s'\n", u.config.ManifestPath)
+	}
+
+	return nil
+}
+
+// repairChunk verifies the copies of chunk and uploads new copies until the
+// configured number of intact ones is met. Copies that are not intact are
+// kept in place of the copies still missing. The chunk is updated in place.
+func (u *Umbra) repairChunk(ctx context.Context, chunk *content.Chunk, crypto *crypto.Crypto, slots providerSlots) chunkRepair {
+	var repair chunkRepair
+
+	// every intact copy holds the same encrypted data, the first one found is
+	// uploaded again
+	var encryptedChunkData []byte
+	var intact, failed []content.ChunkCopy
+
+	for _, c := range chunk.Copies {
+		data, err := u.downloadChunkCopy(ctx, c)
+		if err == nil {
+			_, err = decodeChunk(chunk, data, crypto)
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				repair.err = err
+				return repair
+			}
+			failed = append(failed, c)
+			continue
+		}
+
+		intact = append(intact, c)
+		if encryptedChunkData == nil {
+			encryptedChunkData = data
+		}
+	}
+
+	if encryptedChunkData == nil {
+		repair.lost = true
+		return repair
+	}
+
+	copies := u.config.Repair.Copies
+	if copies == 0 {
+		copies = len(chunk.Copies)
+	}
+
+	upload := &chunkUpload{
+		index:  int(chunk.ID),
+		hash:   chunk.Hash,
+		size:   chunk.Size,
+		codec:  chunk.Compression,
+		copies: intact,
+	}
+
+	repair.err = u.replicateChunk(ctx, upload, encryptedChunkData, copies, nil, slots, nil)
+
+	// a copy failing for a temporary reason must not lower the redundancy
+	missing := max(copies-len(upload.copies), 0)
+	kept := failed[:min(missing, len(failed))]
+
+	repair.dropped = len(failed) - len(kept)
+	repair.uploaded = len(upload.copies) - len(intact)
+	chunk.Copies = append(upload.copies, kept...)
+
+	return repair
+}
+
+// replaceCopies uploads new copies of chunk in place of the copies for which
+// replace returns true, to providers not named in avoid. Replaced copies are
+// kept until a new copy takes their place.
+func (u *Umbra) replaceCopies(ctx context.Context, chunk *content.Chunk, crypto *crypto.Crypto, slots providerSlots, replace func(content.ChunkCopy) bool, avoid []string) chunkRepair {
+	var repair chunkRepair
+
+	var kept, replaced []content.ChunkCopy
+	for _, c := range chunk.Copies {
+		if replace(c) {
+			replaced = append(replaced, c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+
+	if len(replaced) == 0 {
+		return repair
+	}
+
+	// every intact copy holds the same encrypted data, kept copies are tried
+	// first
+	var encryptedChunkData []byte
+	for _, c := range slices.Concat(kept, replaced) {
+		data, err := u.downloadChunkCopy(ctx, c)
+		if err == nil {
+			_, err = decodeChunk(chunk, data, crypto)
+		}
+
+		if err == nil {
+			encryptedChunkData = data
+			break
+		}
+
+		if ctx.Err() != nil {
+			repair.err = err
+			return repair
+		}
+	}
+
+	if encryptedChunkData == nil {
+		repair.lost = true
+		return repair
+	}
+
+	upload := &chunkUpload{
+		index:  int(chunk.ID),
+		hash:   chunk.Hash,
+		size:   chunk.Size,
+		codec:  chunk.Compression,
+		copies: kept,
+	}
+
+	repair.err = u.replicateChunk(ctx, upload, encryptedChunkData, len(chunk.Copies), avoid, slots, nil)
+
+	repair.uploaded = len(upload.copies) - len(kept)
+	repair.dropped = min(repair.uploaded, len(replaced))
+	chunk.Copies = append(upload.copies, replaced[repair.dropped:]...)
+
+	return repair
+}
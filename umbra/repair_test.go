@@ -0,0 +1,91 @@
+package umbra
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/henomis/umbra/config"
+	"github.com/henomis/umbra/internal/provider/local"
+)
+
+func TestRepair(t *testing.T) {
+	dir := t.TempDir()
+
+	manifestPath := uploadTestFile(t, dir, testData(50000), &config.Config{
+		Providers: []string{"alpha", "beta"},
+		Upload:    &config.Upload{ChunkSize: 10000, Copies: 2},
+	})
+
+	repair := func(providers ...string) error {
+		u := newTestUmbra(t, dir, &config.Config{
+			ManifestPath: manifestPath,
+			Providers:    providers,
+			Repair:       &config.Repair{Parallel: 2},
+		})
+		return u.Repair(context.Background())
+	}
+
+	// the lost copies are replaced on the given providers only
+	clearStore(t, dir, "beta")
+	if err := repair("alpha", "gamma"); err != nil {
+		t.Fatalf("Repair() error = %v", err)
+	}
+
+	if beta, gamma := len(storedCopies(t, dir, "beta")), len(storedCopies(t, dir, "gamma")); beta != 0 || gamma != 5 {
+		t.Fatalf("copies stored by beta, gamma = %d, %d, want 0, 5", beta, gamma)
+	}
+
+	u := newTestUmbra(t, dir, &config.Config{ManifestPath: manifestPath, Verify: &config.Verify{}})
+	report, err := u.Verify(context.Background())
+	if err != nil || report.Status() != Healthy || report.Redundancy() != 2 {
+		t.Fatalf("Verify() = %v, %v, want healthy with 2 copies", report.Status(), err)
+	}
+
+	// a chunk without intact copies cannot be repaired
+	clearStore(t, dir, "gamma")
+	if err := os.WriteFile(storeFiles(t, dir, "alpha")[0], []byte("corrupted"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repair(testStores...); !errors.Is(err, ErrChunkUnrecoverable) {
+		t.Fatalf("Repair() error = %v, want ErrChunkUnrecoverable", err)
+	}
+}
+
+func TestRepairKeepsFailedCopies(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(50000)
+
+	manifestPath := uploadTestFile(t, dir, data, &config.Config{
+		Providers: []string{"alpha", "beta"},
+		Upload:    &config.Upload{ChunkSize: 10000, Copies: 2},
+	})
+
+	// alpha is briefly unreachable, and no other provider can take its copies
+	u := newTestUmbra(t, dir, &config.Config{
+		ManifestPath: manifestPath,
+		Providers:    []string{"alpha", "beta"},
+		ProviderOptions: map[string]map[string]string{
+			"alpha": {local.OptionUploadFailureRate: "1", local.OptionDownloadFailureRate: "1"},
+		},
+		Repair: &config.Repair{},
+	})
+	if err := u.Repair(context.Background()); !errors.Is(err, ErrNoProviderAvailable) {
+		t.Fatalf("Repair() error = %v, want ErrNoProviderAvailable", err)
+	}
+
+	for _, chunk := range loadTestContent(t, dir, manifestPath).Chunks {
+		if len(chunk.Copies) != 2 {
+			t.Fatalf("chunk %d copies = %d, want 2", chunk.ID, len(chunk.Copies))
+		}
+	}
+
+	// the copies of alpha are still read once it is back
+	clearStore(t, dir, "beta")
+	if got := downloadTestFile(t, dir, manifestPath, &config.Config{}); !bytes.Equal(got, data) {
+		t.Fatal("downloaded data differs from the uploaded one")
+	}
+}
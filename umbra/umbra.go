@@ -1,10 +1,20 @@
 package umbra
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
 	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
 
 	"github.com/henomis/umbra/config"
 	"github.com/henomis/umbra/internal/provider"
+	"github.com/henomis/umbra/internal/selection"
 )
 
 // Umbra is the main struct that holds the configuration, providers, and logger.
@@ -12,21 +22,45 @@ type Umbra struct {
 	config    *config.Config
 	providers []provider.Provider
 	progress  *mpb.Progress
+	output    io.Writer
+	latency   *latencyTracker
+	journal   *journal
+	selection selection.Policy
+
+	// mu guards unlisted, the providers created on demand
+	mu       sync.Mutex
+	unlisted map[string]provider.Provider
 }
 
 // New creates a configured Umbra instance, validating the given configuration
 // and initializing the logging and provider stack according to its settings.
-func New(config *config.Config) (*Umbra, error) {
+func New(config *config.Config) (_ *Umbra, err error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
+	// keep standard output clean when it carries the downloaded data
+	output := io.Writer(os.Stdout)
+	if config.Download != nil && config.Download.IsStream() {
+		output = os.Stderr
+	}
+
 	u := &Umbra{
 		config:   config,
-		progress: mpb.New(),
+		progress: mpb.New(mpb.WithOutput(output)),
+		output:   output,
+		latency:  newLatencyTracker(),
+		unlisted: make(map[string]provider.Provider),
 	}
 
-	err := u.buildProviders()
+	// stop the plugins already started when the configuration is rejected
+	defer func() {
+		if err != nil {
+			_ = u.Close()
+		}
+	}()
+
+	err = u.buildProviders()
 	if err != nil {
 		return nil, err
 	}
@@ -35,5 +69,71 @@ func New(config *config.Config) (*Umbra, error) {
 		return nil, ErrCopiesExceedProviders
 	}
 
+	if config.Repair != nil && config.Repair.Copies > len(u.providers) {
+		return nil, ErrCopiesExceedProviders
+	}
+
+	// the per provider settings only apply to the configured providers
+	var names []string
+	if config.Upload != nil {
+		names = slices.Collect(maps.Keys(config.Upload.ProviderParallel))
+	}
+	for _, name := range slices.Concat(names, slices.Collect(maps.Keys(config.ProviderWeights)), slices.Collect(maps.Keys(config.ProviderTags))) {
+		if !u.isConfigured(name) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+		}
+	}
+
+	// copies may be moved off providers no longer configured, such as
+	// disabled ones
+	if config.Evacuate != nil {
+		for _, name := range config.Evacuate.Providers {
+			if !u.isConfigured(name) && !u.isProvider(name) {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+			}
+		}
+	}
+
+	u.selection, err = selection.New(config.Selection, config.Providers, config.ProviderWeights)
+	if err != nil {
+		return nil, err
+	}
+
 	return u, nil
 }
+
+// Close releases the providers holding resources, such as the processes of
+// the plugins, including the providers created on demand.
+func (u *Umbra) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var errs []error
+	for _, p := range slices.Concat(u.providers, slices.Collect(maps.Values(u.unlisted))) {
+		if closer, ok := p.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// newProgressBar creates a progress bar with the given name and total, or
+// returns nil when quiet output is enabled.
+func (u *Umbra) newProgressBar(name string, total int64) *mpb.Bar {
+	if u.config.Quiet {
+		return nil
+	}
+
+	return u.progress.New(
+		total,
+		mpb.BarStyle().Rbound("|"),
+		mpb.PrependDecorators(
+			decor.Name(name, decor.WC{W: 12}),
+			decor.CountersNoUnit("%d/%d", decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(
+			decor.Percentage(),
+		),
+	)
+}
@@ -0,0 +1,358 @@
+package umbra
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	mathrand "math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/henomis/umbra/config"
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/provider"
+	"github.com/henomis/umbra/internal/provider/local"
+)
+
+const testPassword = "correct horse battery staple"
+
+// testStores are the providers of the tests, aliases of the local provider
+// each storing its copies in its own directory.
+var testStores = []string{"alpha", "beta", "gamma"}
+
+// newTestUmbra creates an Umbra over the local stores kept in dir. The store
+// options of cfg are added to the directory of each store.
+func newTestUmbra(t *testing.T, dir string, cfg *config.Config) *Umbra {
+	t.Helper()
+
+	if cfg.ManifestPath == "" {
+		cfg.ManifestPath = filepath.Join(dir, "file.umbra")
+	}
+	cfg.Password = testPassword
+	cfg.Quiet = true
+
+	options := cfg.ProviderOptions
+	cfg.ProviderAliases = make(map[string]string)
+	cfg.ProviderOptions = make(map[string]map[string]string)
+	for _, store := range testStores {
+		cfg.ProviderAliases[store] = local.Name
+		cfg.ProviderOptions[store] = mergeOptions(provider.Options{
+			local.OptionDir: filepath.Join(dir, store),
+		}, options[store])
+	}
+
+	u, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	return u
+}
+
+// testData returns n bytes of reproducible, incompressible data.
+func testData(n int) []byte {
+	r := mathrand.New(mathrand.NewPCG(1, uint64(n)))
+
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(r.Uint32())
+	}
+
+	return data
+}
+
+// uploadTestFile writes data to an input file of dir and uploads it with the
+// upload settings of cfg, returning the manifest path.
+func uploadTestFile(t *testing.T, dir string, data []byte, cfg *config.Config) string {
+	t.Helper()
+
+	cfg.Upload.InputFilePath = filepath.Join(dir, "input")
+	if err := os.WriteFile(cfg.Upload.InputFilePath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	u := newTestUmbra(t, dir, cfg)
+	if err := u.Upload(context.Background()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	return cfg.ManifestPath
+}
+
+// downloadTestFile downloads the file of the manifest with the store options
+// of cfg, and returns its content.
+func downloadTestFile(t *testing.T, dir, manifestPath string, cfg *config.Config) []byte {
+	t.Helper()
+
+	output := filepath.Join(dir, "output")
+	os.Remove(output)
+
+	cfg.ManifestPath = manifestPath
+	if cfg.Download == nil {
+		cfg.Download = &config.Download{}
+	}
+	cfg.Download.OutputFilePath = output
+
+	u := newTestUmbra(t, dir, cfg)
+	if err := u.Download(context.Background()); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// storedCopies returns the sizes of the copies kept by the store.
+func storedCopies(t *testing.T, dir, store string) []int64 {
+	t.Helper()
+
+	entries, err := os.ReadDir(filepath.Join(dir, store))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, info.Size())
+	}
+
+	return sizes
+}
+
+// storeFiles returns the paths of the copies kept by the store.
+func storeFiles(t *testing.T, dir, store string) []string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, store, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return paths
+}
+
+// clearStore deletes every copy kept by the store.
+func clearStore(t *testing.T, dir, store string) {
+	t.Helper()
+
+	for _, path := range storeFiles(t, dir, store) {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// loadTestContent decrypts the manifest and returns the content it records.
+func loadTestContent(t *testing.T, dir, manifestPath string) *content.Content {
+	t.Helper()
+
+	u := newTestUmbra(t, dir, &config.Config{ManifestPath: manifestPath})
+	c, _, err := u.loadContent(context.Background())
+	if err != nil {
+		t.Fatalf("loadContent() error = %v", err)
+	}
+
+	return c
+}
+
+// gauge tracks the peak number of concurrent requests.
+type gauge struct {
+	mu            sync.Mutex
+	current, peak int
+}
+
+func (g *gauge) enter() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.current++
+	g.peak = max(g.peak, g.current)
+}
+
+func (g *gauge) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.current--
+}
+
+// gaugedProvider holds its requests for a while, tracking their concurrency
+// overall and for the provider.
+type gaugedProvider struct {
+	provider.Provider
+	all, own *gauge
+}
+
+func (g *gaugedProvider) Upload(ctx context.Context, payload []byte) (content.Meta, error) {
+	g.all.enter()
+	g.own.enter()
+	defer g.all.leave()
+	defer g.own.leave()
+
+	time.Sleep(100 * time.Millisecond)
+	return g.Provider.Upload(ctx, payload)
+}
+
+func (g *gaugedProvider) Download(ctx context.Context, meta content.Meta) ([]byte, error) {
+	g.all.enter()
+	g.own.enter()
+	defer g.all.leave()
+	defer g.own.leave()
+
+	time.Sleep(100 * time.Millisecond)
+	return g.Provider.Download(ctx, meta)
+}
+
+// gaugeProviders wraps the providers of u in gaugedProviders, returning the
+// overall gauge and the gauge of each provider.
+func gaugeProviders(u *Umbra) (*gauge, map[string]*gauge) {
+	all := &gauge{}
+	own := make(map[string]*gauge)
+	for i, p := range u.providers {
+		own[p.Name()] = &gauge{}
+		u.providers[i] = &gaugedProvider{Provider: p, all: all, own: own[p.Name()]}
+	}
+
+	return all, own
+}
+
+func TestUmbraLocalRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(100000)
+
+	const maxSize = 16384
+
+	manifestPath := uploadTestFile(t, dir, data, &config.Config{
+		ProviderOptions: map[string]map[string]string{
+			"alpha": {provider.OptionMaxSize: "16384", local.OptionUploadFailureRate: "0.2"},
+			"beta":  {provider.OptionMaxSize: "16384"},
+			"gamma": {provider.OptionMaxSize: "16384", local.OptionUploadFailureRate: "1"},
+		},
+		Upload: &config.Upload{
+			Chunks:     config.AutoChunks,
+			Copies:     2,
+			Retries:    8,
+			RetryDelay: time.Millisecond,
+		},
+	})
+
+	// every copy fits the simulated size, and the failing store holds none
+	alpha, beta := storedCopies(t, dir, "alpha"), storedCopies(t, dir, "beta")
+	if len(alpha) != len(beta) || len(alpha) < 100000/maxSize+1 {
+		t.Fatalf("stored copies = %d, %d, want one per chunk in each store", len(alpha), len(beta))
+	}
+
+	for _, size := range append(alpha, beta...) {
+		if size > maxSize {
+			t.Fatalf("stored copy size = %d, want at most %d", size, maxSize)
+		}
+	}
+
+	if gamma := storedCopies(t, dir, "gamma"); len(gamma) != 0 {
+		t.Fatalf("failing store copies = %d, want 0", len(gamma))
+	}
+
+	// every chunk is read from the copy of the store not failing
+	got := downloadTestFile(t, dir, manifestPath, &config.Config{
+		ProviderOptions: map[string]map[string]string{
+			"beta": {local.OptionDownloadFailureRate: "1"},
+		},
+	})
+	if !bytes.Equal(got, data) {
+		t.Fatal("downloaded data differs from the uploaded one")
+	}
+}
+
+// closingProvider records whether it was closed.
+type closingProvider struct {
+	provider.Provider
+	closed bool
+}
+
+func (c *closingProvider) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestUmbraClose(t *testing.T) {
+	dir := t.TempDir()
+	u := newTestUmbra(t, dir, &config.Config{})
+
+	listed := &closingProvider{Provider: u.providers[0]}
+	unlisted := &closingProvider{Provider: u.providers[1]}
+	u.providers[0] = listed
+	u.unlisted["unlisted"] = unlisted
+
+	if err := u.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if !listed.closed || !unlisted.closed {
+		t.Fatalf("closed = %v, %v, want listed and unlisted providers closed", listed.closed, unlisted.closed)
+	}
+}
+
+func TestUmbraProviderSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want error
+	}{
+		{"configured", config.Config{ProviderWeights: map[string]int{"alpha": 2}}, nil},
+		{"alias", config.Config{Upload: &config.Upload{ProviderParallel: map[string]int{"gamma": 1}}}, nil},
+		{"registered parallel", config.Config{Upload: &config.Upload{ProviderParallel: map[string]int{"termbin": 1}}}, ErrUnknownProvider},
+		{"registered weight", config.Config{ProviderWeights: map[string]int{"termbin": 2}}, ErrUnknownProvider},
+		{"unknown tag", config.Config{ProviderTags: map[string]map[string]string{"nope": {"region": "eu"}}}, ErrUnknownProvider},
+		{"registered evacuated", config.Config{Evacuate: &config.Evacuate{Providers: []string{"termbin"}}}, nil},
+		{"unknown evacuated", config.Config{Evacuate: &config.Evacuate{Providers: []string{"nope"}}}, ErrUnknownProvider},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			cfg := tt.cfg
+			cfg.ManifestPath = filepath.Join(dir, "file.umbra")
+			cfg.Password = testPassword
+			cfg.Providers = []string{"alpha", "beta"}
+			if cfg.Evacuate == nil {
+				upload := config.Upload{InputFilePath: filepath.Join(dir, "input"), Chunks: 1, Copies: 1}
+				if cfg.Upload != nil {
+					upload.ProviderParallel = cfg.Upload.ProviderParallel
+				}
+				cfg.Upload = &upload
+			}
+			cfg.ProviderAliases = make(map[string]string)
+			cfg.ProviderOptions = make(map[string]map[string]string)
+			for _, store := range testStores {
+				cfg.ProviderAliases[store] = local.Name
+				cfg.ProviderOptions[store] = provider.Options{local.OptionDir: filepath.Join(dir, store)}
+			}
+
+			u, err := New(&cfg)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("New() error = %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			defer u.Close()
+
+			// the names are checked without creating the providers
+			if len(u.unlisted) != 0 {
+				t.Fatalf("unlisted providers = %v, want none", u.unlisted)
+			}
+		})
+	}
+}
@@ -5,14 +5,22 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	mathrand "math/rand/v2"
 	"os"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/vbauerster/mpb/v8"
-	"github.com/vbauerster/mpb/v8/decor"
 
+	"github.com/henomis/umbra/config"
+	"github.com/henomis/umbra/internal/compress"
 	"github.com/henomis/umbra/internal/content"
 	"github.com/henomis/umbra/internal/crypto"
 	"github.com/henomis/umbra/internal/ghost"
@@ -20,18 +28,28 @@ import (
 	"github.com/henomis/umbra/internal/provider"
 )
 
+// maxRetryDelay caps the exponential backoff between upload retries.
+const maxRetryDelay = time.Minute
+
+// maxAutoChunkSize caps the automatic chunk size, since every upload worker
+// holds a chunk in memory and providers such as s3 accept objects of
+// gigabytes.
+const maxAutoChunkSize = 64 << 20
+
 // Upload orchestrates the chunk sizing, encryption setup, content creation, and
-// manifest generation for the configured Umbra instance.
+// manifest generation for the configured Umbra instance. Progress is recorded
+// in a journal, so that an interrupted upload can be resumed.
 func (u *Umbra) Upload(ctx context.Context) error {
-	// calculate chunk size
-	chunkSize, chunks, fileSize, err := u.calculateChunkSize()
-	if err != nil {
-		return fmt.Errorf("failed to calculate chunk size: %w", err)
+	if u.config.Upload.Abandon {
+		return u.abandonUpload(ctx)
 	}
 
-	// check chunk size against providers' max
-	if chunkSize > u.getMaxChunkSizeForProviders() {
-		return ErrChunkSizeExceedsProviderLimit
+	if u.config.Upload.DryRun {
+		plan, err := u.Plan(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to plan upload: %w", err)
+		}
+		return u.printUploadPlan(plan)
 	}
 
 	// create crypto and manifest
@@ -40,124 +58,422 @@ func (u *Umbra) Upload(ctx context.Context) error {
 		return fmt.Errorf("failed to create crypto: %w", err)
 	}
 
-	content, err := u.createContent(ctx, fileSize, chunks, chunkSize, crypto)
+	// resuming restores the crypto and chunking parameters of the journal
+	if u.config.Upload.Resume {
+		if err := u.resumeJournal(crypto); err != nil {
+			return fmt.Errorf("failed to resume upload: %w", err)
+		}
+	}
+
+	// calculate chunk size
+	chunkSize, chunks, err := u.calculateChunkSize()
 	if err != nil {
-		return fmt.Errorf("failed to create content: %w", err)
+		return fmt.Errorf("failed to calculate chunk size: %w", err)
 	}
 
-	contentData, err := content.Encode()
+	if err := u.checkChunkSize(chunkSize); err != nil {
+		return err
+	}
+
+	if !u.config.Upload.Resume {
+		if err := u.createJournal(crypto, chunkSize); err != nil {
+			return fmt.Errorf("failed to create journal: %w", err)
+		}
+	}
+
+	input, err := u.openInput()
 	if err != nil {
-		return fmt.Errorf("failed to encode content: %w", err)
+		return fmt.Errorf("failed to open input file: %w", err)
 	}
+	defer input.Close()
 
-	manifestData := bytes.NewBuffer(nil)
-	manifest := manifest.New(crypto)
-	if err := manifest.Encode(manifestData, contentData); err != nil {
-		return fmt.Errorf("failed to write manifest: %w", err)
+	content, err := u.createContent(ctx, input, chunks, chunkSize, crypto)
+	if err != nil {
+		u.printJournalSaved()
+		return fmt.Errorf("failed to create content: %w", err)
 	}
 
-	if err := u.saveManifest(ctx, manifestData.Bytes()); err != nil {
+	if err := u.saveContent(ctx, content, crypto); err != nil {
+		u.printJournalSaved()
 		return fmt.Errorf("failed to save manifest: %w", err)
 	}
 
+	if u.journal != nil {
+		if err := u.journal.remove(); err != nil {
+			return fmt.Errorf("failed to remove journal: %w", err)
+		}
+	}
+
 	expire := u.getProviderMinExpireDuration()
 
 	if !u.config.Quiet {
-		fmt.Printf("✅ Upload completed. Manifest '%s' expires in: %s\n", u.config.ManifestPath, expire.String())
+		fmt.Fprintf(u.output, "✅ Upload completed. Manifest '%s' expires in: %s\n", u.config.ManifestPath, expire.String())
 	}
 
 	return nil
 }
 
-// createContent builds the content manifest by reading the input file in
-// chunkSize increments, hashing each chunk, and delegating encryption and
-// upload to processChunk while reusing the provided crypto helper.
-func (u *Umbra) createContent(ctx context.Context, size, nChunks, chunkSize int64, crypto *crypto.Crypto) (*content.Content, error) {
-	buffer := make([]byte, chunkSize)
+// createJournal starts a new journal for the upload. It refuses to replace an
+// existing journal, which would lose track of the copies it records. Journals
+// are not used when streaming, since the input cannot be read again.
+func (u *Umbra) createJournal(chunkCrypto *crypto.Crypto, chunkSize int64) error {
+	if u.config.Upload.IsStream() {
+		return nil
+	}
 
-	var bar *mpb.Bar
+	path := u.journalPath()
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%w: '%s'", ErrJournalExists, path)
+	}
 
-	if !u.config.Quiet {
-		bar = u.progress.New(
-			nChunks*int64(u.config.Upload.Copies),
-			mpb.BarStyle().Rbound("|"),
-			mpb.PrependDecorators(
-				decor.Name("Uploading: ", decor.WC{W: 12}),
-				decor.CountersNoUnit("%d/%d", decor.WCSyncWidth),
-			),
-			mpb.AppendDecorators(
-				decor.Percentage(),
-			),
-		)
-	}
-
-	// calculate file hash
-	fileHash, err := fileSHA256(u.config.Upload.InputFilePath)
+	input, err := statInput(u.config.Upload.InputFilePath)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	// the journal has its own salt and nonce, apart from the chunk ones
+	journalCrypto, err := crypto.New([]byte(u.config.Password))
+	if err != nil {
+		return err
 	}
 
-	// create content
-	content := content.New(fileHash, size)
+	u.journal = newJournal(path, journalCrypto, journalState{
+		Input:     input,
+		ChunkSize: chunkSize,
+		Compress:  u.config.Upload.Compress,
+		Crypto:    *chunkCrypto.Parameters(),
+	})
+
+	return u.journal.save()
+}
+
+// resumeJournal loads the journal of an interrupted upload, checks that the
+// input file did not change, and restores the crypto and chunking parameters
+// used by the copies already uploaded.
+func (u *Umbra) resumeJournal(crypto *crypto.Crypto) error {
+	if u.config.Upload.IsStream() {
+		return ErrStreamResume
+	}
 
-	inputFile, err := os.Open(u.config.Upload.InputFilePath)
+	journal, err := loadJournal(u.journalPath(), []byte(u.config.Password))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer inputFile.Close()
 
-	for {
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
-			return nil, err
+	input, err := statInput(u.config.Upload.InputFilePath)
+	if err != nil {
+		return err
+	}
+
+	if !input.matches(journal.state.Input) {
+		return ErrJournalInputMismatch
+	}
+
+	if input.modified(journal.state.Input) && !u.config.Quiet {
+		fmt.Fprintf(u.output, "⚠️ Input file modified since the interrupted upload: chunks whose hash changed are uploaded again.\n")
+	}
+
+	if err := crypto.SetParameters(&journal.state.Crypto); err != nil {
+		return err
+	}
+
+	u.config.Upload.ChunkSize = journal.state.ChunkSize
+	u.config.Upload.Chunks = 0
+	u.config.Upload.Compress = journal.state.Compress
+	u.journal = journal
+
+	return nil
+}
+
+// abandonUpload lists the copies recorded in the journal of an interrupted
+// upload, which are orphaned since no manifest references them, deletes them
+// from the providers supporting it, and removes the journal.
+func (u *Umbra) abandonUpload(ctx context.Context) error {
+	journal, err := loadJournal(u.journalPath(), []byte(u.config.Password))
+	if err != nil {
+		return fmt.Errorf("failed to load journal: %w", err)
+	}
+
+	copies := journal.copies()
+
+	outcomes := make([]string, len(copies))
+	for i, c := range copies {
+		outcomes[i] = u.deleteCopy(ctx, c)
+	}
+
+	u.printOrphanedCopies(copies, outcomes)
+
+	if err := journal.remove(); err != nil {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+
+	return nil
+}
+
+func (u *Umbra) printOrphanedCopies(copies []content.ChunkCopy, outcomes []string) {
+	if u.config.Quiet {
+		return
+	}
+
+	w := tabwriter.NewWriter(u.output, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Orphaned copies:\t%d\n", len(copies))
+	for i, c := range copies {
+		fmt.Fprintf(w, "\t%s\t%s\t%s\n", c.Provider, string(c.Meta), outcomes[i])
+	}
+	w.Flush()
+}
+
+// deleteCopy deletes the copy from its provider when the provider supports
+// it, and describes the outcome.
+func (u *Umbra) deleteCopy(ctx context.Context, c content.ChunkCopy) string {
+	p, err := u.getProviderByName(c.Provider)
+	if err != nil {
+		return "kept"
+	}
+
+	deleter, ok := p.(provider.Deleter)
+	if !ok {
+		return "kept"
+	}
+
+	if err := deleter.Delete(ctx, c.Meta); errors.Is(err, provider.ErrDeleteUnsupported) {
+		return "kept"
+	} else if err != nil {
+		return fmt.Sprintf("kept (%v)", err)
+	}
+
+	return "deleted"
+}
+
+func (u *Umbra) printJournalSaved() {
+	if u.config.Quiet || u.journal == nil {
+		return
+	}
+
+	fmt.Fprintf(u.output, "⚠️ Upload interrupted. Journal saved to '%s': run again with --resume to continue or with --abandon to list the orphaned copies.\n", u.journal.path)
+}
+
+// openInput opens the configured input file, or standard input when streaming.
+func (u *Umbra) openInput() (io.ReadCloser, error) {
+	if u.config.Upload.IsStream() {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return os.Open(u.config.Upload.InputFilePath)
+}
+
+// chunkUpload holds the outcome of reading and uploading a single chunk. It is
+// filled concurrently by the upload workers and recorded into the content in
+// reading order once every upload is completed.
+type chunkUpload struct {
+	index  int
+	hash   [32]byte
+	size   int64
+	codec  string
+	zero   bool
+	ref    uint32
+	copies []content.ChunkCopy
+}
+
+// createContent builds the content manifest by reading the input in chunkSize
+// increments, hashing each chunk, and delegating encryption and upload to a
+// bounded pool of createChunk workers while reusing the provided crypto helper.
+// The file hash and size are computed incrementally, so the input is read only
+// once. A negative nChunks means the number of chunks is unknown until the
+// input is exhausted.
+func (u *Umbra) createContent(ctx context.Context, input io.Reader, nChunks, chunkSize int64, crypto *crypto.Crypto) (*content.Content, error) {
+	buffer := make([]byte, chunkSize)
+	copies := int64(u.config.Upload.Copies)
+
+	bar := u.newProgressBar("Uploading: ", max(nChunks, 0)*copies)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, max(u.config.Upload.Parallel, 1))
+	slots := u.newProviderSlots()
+
+	// create content, hash and size are finalized once the input is consumed
+	content := content.New([32]byte{}, 0)
+
+	fileHash := sha256.New()
+	reader := io.TeeReader(input, fileHash)
+
+	// chunks already uploaded by hash, repeated chunks reference them
+	uploaded := make(map[[32]byte]uint32)
+	uploads := make([]*chunkUpload, 0)
+
+	for ctx.Err() == nil {
+		n, err := io.ReadFull(reader, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			cancel(err)
+			break
 		}
 		if n == 0 {
 			break
 		}
 
 		chunkData := buffer[:n]
+		content.Size += int64(n)
 
-		err = u.createChunk(ctx, content, chunkData, crypto, bar)
-		if err != nil {
-			return nil, err
+		if bar != nil && nChunks < 0 {
+			bar.SetTotal(bar.Current()+copies, false)
+		}
+
+		upload := &chunkUpload{
+			index: len(uploads),
+			hash:  sha256.Sum256(chunkData),
+			size:  int64(n),
 		}
+		uploads = append(uploads, upload)
+
+		// all-zero and repeated chunks are recorded without being uploaded
+		if isZero(chunkData) {
+			upload.zero = true
+		} else if id, ok := uploaded[upload.hash]; ok {
+			upload.ref = id
+		}
+
+		if upload.zero || upload.ref != 0 {
+			if bar != nil {
+				bar.IncrBy(int(copies))
+			}
+			continue
+		}
+
+		// chunk IDs follow the reading order
+		uploaded[upload.hash] = uint32(len(uploads))
+
+		// reuse the copies uploaded by an interrupted run
+		if journaled, ok := u.journal.lookup(upload.index, upload.hash); ok {
+			upload.codec = journaled.Codec
+			upload.copies = journaled.Copies
+			if bar != nil {
+				bar.IncrBy(min(len(upload.copies), int(copies)))
+			}
+
+			if len(upload.copies) >= int(copies) {
+				continue
+			}
+		}
+
+		// wait for a free worker
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		data := bytes.Clone(chunkData)
+		wg.Go(func() {
+			defer func() { <-workers }()
+
+			if err := u.createChunk(ctx, upload, data, crypto, slots, bar); err != nil {
+				cancel(err)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		if bar != nil {
+			bar.Abort(false)
+			bar.Wait()
+		}
+		return nil, err
 	}
 
 	if bar != nil {
+		if nChunks < 0 {
+			bar.SetTotal(-1, true)
+		}
 		bar.Wait()
 	}
 
+	for _, upload := range uploads {
+		addChunkUpload(content, upload)
+	}
+
+	copy(content.Hash[:], fileHash.Sum(nil))
+	content.Root = content.ComputeRoot()
+
 	return content, nil
 }
 
-// createChunk encrypts the given chunk, uploads it to the configured providers,
-// and records the resulting metadata into the content manifest.
-func (u *Umbra) createChunk(ctx context.Context, content *content.Content, chunkData []byte, crypto *crypto.Crypto, bar *mpb.Bar) error {
-	providers := make([]provider.Provider, 0)
-	var chunkID *uint32
+// addChunkUpload records an uploaded chunk into the content.
+func addChunkUpload(content *content.Content, upload *chunkUpload) {
+	switch {
+	case upload.zero:
+		content.AddZero(upload.hash, upload.size)
+	case upload.ref != 0:
+		content.AddRef(upload.hash, upload.size, upload.ref)
+	default:
+		var chunkID *uint32
+		for _, c := range upload.copies {
+			id := content.Add(upload.hash, upload.size, upload.codec, chunkID, c)
+			chunkID = &id
+		}
+	}
+}
+
+// createChunk compresses and encrypts the given chunk, uploads it to the configured
+// providers, and records the resulting copies into upload.
+func (u *Umbra) createChunk(ctx context.Context, upload *chunkUpload, chunkData []byte, crypto *crypto.Crypto, slots providerSlots, bar *mpb.Bar) error {
+	// compress chunk
+	payload, codec, err := compress.Compress(u.config.Upload.Compress, chunkData)
+	if err != nil {
+		return err
+	}
 
 	// encrypt chunk
-	chunkHash := sha256.Sum256(chunkData)
-	encryptedChunkData, err := crypto.Encode(chunkData, chunkHash[:])
+	encryptedChunkData, err := crypto.Encode(payload, upload.hash[:])
 	if err != nil {
 		return err
 	}
 
-	for range u.config.Upload.Copies {
-		provider, err := u.getUniqueRadomProvider(providers)
+	upload.codec = codec
+
+	return u.replicateChunk(ctx, upload, encryptedChunkData, u.config.Upload.Copies, nil, slots, bar)
+}
+
+// replicateChunk uploads the encrypted chunk data to providers not holding a
+// copy of the chunk yet, until upload has the given number of copies. A
+// provider failing after every retry is replaced by another one. Providers
+// named in avoid never receive a copy.
+func (u *Umbra) replicateChunk(ctx context.Context, upload *chunkUpload, encryptedChunkData []byte, copies int, avoid []string, slots providerSlots, bar *mpb.Bar) error {
+	// providers that failed or must be avoided
+	excluded := slices.Clone(avoid)
+
+	var uploadErr error
+
+	for len(upload.copies) < copies {
+		provider, err := u.selectProvider(int64(len(encryptedChunkData)), copyProviders(upload.copies), excluded)
 		if err != nil {
+			if uploadErr != nil {
+				return fmt.Errorf("%w: %w", err, uploadErr)
+			}
 			return err
 		}
 
-		meta, err := provider.Upload(ctx, encryptedChunkData)
+		meta, err := u.uploadWithRetry(ctx, slots, provider, encryptedChunkData)
 		if err != nil {
-			return err
+			if ctx.Err() != nil {
+				return err
+			}
+
+			// fall back to another provider not holding the chunk
+			excluded = append(excluded, provider.Name())
+			uploadErr = err
+			continue
 		}
 
-		providers = append(providers, provider)
-		id := content.Add(chunkHash, int64(len(chunkData)), provider.Name(), chunkID, meta)
-		if chunkID == nil {
-			chunkID = &id
+		chunkCopy := content.NewChunkCopy(provider.Name(), meta, time.Now(), provider.Expire())
+		upload.copies = append(upload.copies, chunkCopy)
+
+		if err := u.journal.record(upload.index, upload.hash, upload.codec, chunkCopy); err != nil {
+			return err
 		}
 
 		if bar != nil {
@@ -168,46 +484,231 @@ func (u *Umbra) createChunk(ctx context.Context, content *content.Content, chunk
 	return nil
 }
 
-// calculateChunkSize determines the chunk size based on configuration and file size.
-func (u *Umbra) calculateChunkSize() (int64, int64, int64, error) {
+// copyProviders returns the names of the providers holding the copies.
+func copyProviders(copies []content.ChunkCopy) []string {
+	names := make([]string, len(copies))
+	for i, c := range copies {
+		names[i] = c.Provider
+	}
+	return names
+}
+
+// uploadWithRetry uploads data to p, retrying failed attempts up to the
+// configured number of times with exponential backoff and jitter. A delay
+// requested by the provider through Retry-After takes precedence, and errors
+// the provider reports as permanent are not retried.
+func (u *Umbra) uploadWithRetry(ctx context.Context, slots providerSlots, p provider.Provider, data []byte) (content.Meta, error) {
+	retries, retryDelay := u.retryPolicy()
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		meta, err := slots.upload(ctx, p, data)
+		if err == nil {
+			u.selection.Observe(p.Name(), int64(len(data)), time.Since(start))
+			return meta, nil
+		}
+
+		if attempt >= retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := backoff(retryDelay, attempt)
+
+		var statusErr *provider.StatusError
+		if errors.As(err, &statusErr) {
+			if !statusErr.Retryable() {
+				return nil, err
+			}
+			if statusErr.RetryAfter > 0 {
+				delay = statusErr.RetryAfter
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// retryPolicy returns the number of retries of a failed upload and the
+// initial delay between them for the running operation.
+func (u *Umbra) retryPolicy() (int, time.Duration) {
+	if u.config.Repair != nil {
+		return u.config.Repair.Retries, u.config.Repair.RetryDelay
+	}
+
+	if u.config.Refresh != nil {
+		return u.config.Refresh.Retries, u.config.Refresh.RetryDelay
+	}
+
+	if u.config.Evacuate != nil {
+		return u.config.Evacuate.Retries, u.config.Evacuate.RetryDelay
+	}
+
+	return u.config.Upload.Retries, u.config.Upload.RetryDelay
+}
+
+// backoff returns the delay before the given retry attempt, doubling base at
+// every attempt up to maxRetryDelay and picking a random value in the upper
+// half of the interval.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	delay := maxRetryDelay
+	if attempt < 32 {
+		delay = min(base<<attempt, maxRetryDelay)
+	}
+
+	return delay/2 + mathrand.N(delay/2+1)
+}
+
+// providerSlots bounds the number of concurrent uploads per provider name.
+// Providers without an entry are not limited.
+type providerSlots map[string]chan struct{}
+
+func (u *Umbra) newProviderSlots() providerSlots {
+	slots := make(providerSlots)
+	if u.config.Upload == nil {
+		return slots
+	}
+
+	for name, limit := range u.config.Upload.ProviderParallel {
+		slots[name] = make(chan struct{}, limit)
+	}
+	return slots
+}
+
+// upload sends data to p once one of its upload slots is free.
+func (s providerSlots) upload(ctx context.Context, p provider.Provider, data []byte) (content.Meta, error) {
+	if slot, ok := s[p.Name()]; ok {
+		select {
+		case slot <- struct{}{}:
+			defer func() { <-slot }()
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	}
+
+	return p.Upload(ctx, data)
+}
+
+// isZero reports whether data holds only zero bytes.
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// calculateChunkSize determines the chunk size and the number of chunks based
+// on configuration and file size. When streaming from standard input the file
+// size is unknown, so an explicit or automatic chunk size is required and the
+// number of chunks is returned as -1.
+func (u *Umbra) calculateChunkSize() (int64, int64, error) {
+	auto := u.config.Upload.Chunks == config.AutoChunks
+
+	if u.config.Upload.IsStream() {
+		if auto {
+			return u.autoChunkSize(), -1, nil
+		}
+
+		if u.config.Upload.ChunkSize <= 0 {
+			return -1, -1, ErrStreamRequiresChunkSize
+		}
+
+		return u.config.Upload.ChunkSize, -1, nil
+	}
+
 	fileInfo, err := os.Stat(u.config.Upload.InputFilePath)
 	if err != nil {
-		return -1, -1, -1, err
+		return -1, -1, err
 	}
 
 	fileSize := fileInfo.Size()
 	chunkSize := u.config.Upload.ChunkSize
-	if u.config.Upload.Chunks > 0 {
+	if auto {
+		// chunks are evened out below the largest accepted size
+		chunkSize = u.autoChunkSize()
+		chunks := max((fileSize+chunkSize-1)/chunkSize, 1)
+		chunkSize = max((fileSize+chunks-1)/chunks, 1)
+	} else if u.config.Upload.Chunks > 0 {
 		chunkSize = (fileSize / int64(u.config.Upload.Chunks)) + 1
 	}
 
 	chunks := (fileSize + chunkSize - 1) / chunkSize
 
-	return chunkSize, chunks, fileSize, nil
+	return chunkSize, chunks, nil
 }
 
-// saveManifest saves the manifest data to the configured path, optionally
-// encoding it using ghost mode or uploading it to a provider.
-func (u *Umbra) saveManifest(ctx context.Context, data []byte) error {
-	result := bytes.NewBuffer(nil)
-	var err error
+// checkChunkSize checks that chunks of chunkSize fit every configured
+// provider even when compression does not shrink them, so that incompressible
+// data cannot fail the upload halfway.
+func (u *Umbra) checkChunkSize(chunkSize int64) error {
+	if compress.Bound(u.config.Upload.Compress, chunkSize) > u.getMaxChunkSizeForProviders() {
+		return ErrChunkSizeExceedsProviderLimit
+	}
 
-	ghostMode := u.config.GhostMode
-	switch ghostMode {
-	case ghost.Image:
-		err = ghost.EncodeToImage(result, data)
-	case ghost.QRCode:
-		err = ghost.EncodeToQR(result, data)
-	default:
-		_, err = result.Write(data)
+	return nil
+}
+
+// autoChunkSize returns the size of the largest chunk whose payload, once
+// compressed with the configured mode, every configured provider accepts, up
+// to maxAutoChunkSize.
+func (u *Umbra) autoChunkSize() int64 {
+	maxSize := min(u.getMaxChunkSizeForProviders(), maxAutoChunkSize)
+
+	// largest chunk whose compression bound fits, the bound growing with n
+	n := sort.Search(int(maxSize)+1, func(n int) bool {
+		return compress.Bound(u.config.Upload.Compress, int64(n)) > maxSize
+	})
+
+	return int64(max(n-1, 1))
+}
+
+// saveContent encodes the content into a manifest protected by crypto and
+// saves it to the configured manifest path. A manifest read from a provider
+// is uploaded again to the same provider.
+func (u *Umbra) saveContent(ctx context.Context, content *content.Content, crypto *crypto.Crypto) error {
+	content.Saved = time.Now().UTC().Truncate(time.Second)
+
+	contentData, err := content.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode content: %w", err)
+	}
+
+	manifestData := bytes.NewBuffer(nil)
+	manifest := manifest.New(crypto)
+	if err := manifest.Encode(manifestData, contentData); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
 	}
 
+	// provider:<provider>:<hash> becomes provider:<provider>
+	if strings.HasPrefix(u.config.ManifestPath, "provider:") {
+		parts := strings.SplitN(u.config.ManifestPath, ":", 3)
+		u.config.ManifestPath = parts[0] + ":" + parts[1]
+	}
+
+	return u.saveManifest(ctx, manifestData.Bytes())
+}
+
+// saveManifest saves the manifest data to the configured path, optionally
+// encoding it using ghost mode or uploading it to a provider.
+func (u *Umbra) saveManifest(ctx context.Context, data []byte) error {
+	result, err := u.encodeGhost(data)
 	if err != nil {
 		return err
 	}
 
 	if !strings.HasPrefix(u.config.ManifestPath, "provider:") {
-		return os.WriteFile(u.config.ManifestPath, result.Bytes(), 0o644)
+		return os.WriteFile(u.config.ManifestPath, result, 0o644)
 	}
 
 	provider, err := u.getProviderByName(strings.TrimPrefix(u.config.ManifestPath, "provider:"))
@@ -215,7 +716,7 @@ func (u *Umbra) saveManifest(ctx context.Context, data []byte) error {
 		return err
 	}
 
-	meta, err := provider.Upload(ctx, result.Bytes())
+	meta, err := provider.Upload(ctx, result)
 	if err != nil {
 		return err
 	}
@@ -225,25 +726,23 @@ func (u *Umbra) saveManifest(ctx context.Context, data []byte) error {
 	return nil
 }
 
-// fileSHA256 computes the SHA-256 hash of the file at the given path and returns
-// it as a fixed-length 32-byte array, along with any error encountered during
-// reading.
-func fileSHA256(path string) ([32]byte, error) {
-	var zero [32]byte
+// encodeGhost encodes the manifest data using the configured ghost mode.
+func (u *Umbra) encodeGhost(data []byte) ([]byte, error) {
+	result := bytes.NewBuffer(nil)
+	var err error
 
-	f, err := os.Open(path)
-	if err != nil {
-		return zero, err
+	switch u.config.GhostMode {
+	case ghost.Image:
+		err = ghost.EncodeToImage(result, data)
+	case ghost.QRCode:
+		err = ghost.EncodeToQR(result, data)
+	default:
+		_, err = result.Write(data)
 	}
-	defer f.Close()
 
-	h := sha256.New()
-
-	if _, err := io.Copy(h, f); err != nil {
-		return zero, err
+	if err != nil {
+		return nil, err
 	}
 
-	var sum [32]byte
-	copy(sum[:], h.Sum(nil))
-	return sum, nil
+	return result.Bytes(), nil
 }
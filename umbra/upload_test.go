@@ -0,0 +1,475 @@
+package umbra
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/henomis/umbra/config"
+	"github.com/henomis/umbra/internal/compress"
+	"github.com/henomis/umbra/internal/content"
+	"github.com/henomis/umbra/internal/provider"
+	"github.com/henomis/umbra/internal/provider/local"
+)
+
+func TestUploadCompress(t *testing.T) {
+	data := slices.Concat(bytes.Repeat([]byte("compressible "), 8000), testData(20000))
+
+	for _, mode := range []string{compress.Gzip, compress.Zstd, compress.Auto} {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+
+			manifestPath := uploadTestFile(t, dir, data, &config.Config{
+				Providers: []string{"alpha"},
+				Upload:    &config.Upload{ChunkSize: 40000, Copies: 1, Compress: mode},
+			})
+
+			c := loadTestContent(t, dir, manifestPath)
+			if c.Chunks[0].Compression != mode && !(mode == compress.Auto && c.Chunks[0].Compression == compress.Zstd) {
+				t.Fatalf("first chunk compression = %q, want %q", c.Chunks[0].Compression, mode)
+			}
+
+			// auto mode stores the incompressible last chunk raw
+			last := c.Chunks[len(c.Chunks)-1]
+			if mode == compress.Auto && last.Compression != compress.None {
+				t.Fatalf("last chunk compression = %q, want raw", last.Compression)
+			}
+
+			var stored int64
+			for _, size := range storedCopies(t, dir, "alpha") {
+				stored += size
+			}
+			if stored >= int64(len(data)) {
+				t.Fatalf("stored size = %d, want less than %d", stored, len(data))
+			}
+
+			if got := downloadTestFile(t, dir, manifestPath, &config.Config{}); !bytes.Equal(got, data) {
+				t.Fatal("downloaded data differs from the uploaded one")
+			}
+		})
+	}
+}
+
+func TestUploadCompressRejectsChunkSize(t *testing.T) {
+	dir := t.TempDir()
+
+	// the chunks fit the limit raw, but not once grown by gzip
+	input := filepath.Join(dir, "input")
+	if err := os.WriteFile(input, testData(40000), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	u := newTestUmbra(t, dir, &config.Config{
+		Providers: []string{"alpha"},
+		ProviderOptions: map[string]map[string]string{
+			"alpha": {provider.OptionMaxSize: "20016"},
+		},
+		Upload: &config.Upload{InputFilePath: input, ChunkSize: 20000, Copies: 1, Compress: compress.Gzip},
+	})
+
+	if err := u.Upload(context.Background()); !errors.Is(err, ErrChunkSizeExceedsProviderLimit) {
+		t.Fatalf("Upload() error = %v, want ErrChunkSizeExceedsProviderLimit", err)
+	}
+
+	if files := storeFiles(t, dir, "alpha"); len(files) != 0 {
+		t.Fatalf("stored copies = %d, want 0", len(files))
+	}
+}
+
+func TestUploadElidesChunks(t *testing.T) {
+	dir := t.TempDir()
+
+	// ten chunks: five all-zero, two repeating the second one
+	block := testData(10000)
+	data := make([]byte, 100000)
+	copy(data[10000:], block)
+	copy(data[30000:], block)
+	copy(data[50000:], block)
+	copy(data[70000:80000], testData(10001))
+	copy(data[90000:], "tail")
+
+	manifestPath := uploadTestFile(t, dir, data, &config.Config{
+		Upload: &config.Upload{ChunkSize: 10000, Copies: 2, Parallel: 4},
+	})
+
+	var zero, ref int
+	for _, chunk := range loadTestContent(t, dir, manifestPath).Chunks {
+		switch {
+		case chunk.Zero:
+			zero++
+		case chunk.Ref != 0:
+			ref++
+		}
+	}
+
+	if zero != 5 || ref != 2 {
+		t.Fatalf("zero, repeated chunks = %d, %d, want 5, 2", zero, ref)
+	}
+
+	var stored int
+	for _, store := range testStores {
+		stored += len(storedCopies(t, dir, store))
+	}
+	if stored != 3*2 {
+		t.Fatalf("stored copies = %d, want 6", stored)
+	}
+
+	if got := downloadTestFile(t, dir, manifestPath, &config.Config{Download: &config.Download{Parallel: 4}}); !bytes.Equal(got, data) {
+		t.Fatal("downloaded data differs from the uploaded one")
+	}
+}
+
+func TestUploadParallel(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(100000)
+
+	cfg := &config.Config{
+		Upload: &config.Upload{
+			InputFilePath:    filepath.Join(dir, "input"),
+			ChunkSize:        10000,
+			Copies:           2,
+			Parallel:         3,
+			ProviderParallel: map[string]int{"alpha": 1},
+		},
+	}
+	if err := os.WriteFile(cfg.Upload.InputFilePath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	u := newTestUmbra(t, dir, cfg)
+
+	all, own := gaugeProviders(u)
+
+	if err := u.Upload(context.Background()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// each worker uploads the copies of its chunk one at a time
+	if all.peak < 2 || all.peak > 3 {
+		t.Fatalf("concurrent uploads = %d, want 2 to 3", all.peak)
+	}
+
+	if own["alpha"].peak != 1 {
+		t.Fatalf("concurrent uploads to alpha = %d, want 1", own["alpha"].peak)
+	}
+
+	if got := downloadTestFile(t, dir, cfg.ManifestPath, &config.Config{}); !bytes.Equal(got, data) {
+		t.Fatal("downloaded data differs from the uploaded one")
+	}
+}
+
+func TestUploadRetry(t *testing.T) {
+	data := testData(50000)
+
+	tests := []struct {
+		name      string
+		providers []string
+		options   map[string]map[string]string
+		retries   int
+		want      error
+		stored    map[string]int
+	}{
+		{
+			name:      "retried",
+			providers: []string{"alpha"},
+			options:   map[string]map[string]string{"alpha": {local.OptionUploadFailureRate: "0.5"}},
+			retries:   30,
+			stored:    map[string]int{"alpha": 5},
+		},
+		{
+			name:      "failed over",
+			providers: []string{"alpha", "beta"},
+			options:   map[string]map[string]string{"alpha": {local.OptionUploadFailureRate: "1"}},
+			retries:   2,
+			stored:    map[string]int{"alpha": 0, "beta": 5},
+		},
+		{
+			name:      "failed",
+			providers: []string{"alpha", "beta"},
+			options: map[string]map[string]string{
+				"alpha": {local.OptionUploadFailureRate: "1"},
+				"beta":  {local.OptionUploadFailureRate: "1"},
+			},
+			want: local.ErrSimulatedFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			cfg := &config.Config{
+				Providers:       tt.providers,
+				ProviderOptions: tt.options,
+				Upload: &config.Upload{
+					InputFilePath: filepath.Join(dir, "input"),
+					ChunkSize:     10000,
+					Copies:        1,
+					Retries:       tt.retries,
+					RetryDelay:    time.Microsecond,
+				},
+			}
+			if err := os.WriteFile(cfg.Upload.InputFilePath, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			u := newTestUmbra(t, dir, cfg)
+			if err := u.Upload(context.Background()); !errors.Is(err, tt.want) {
+				t.Fatalf("Upload() error = %v, want %v", err, tt.want)
+			}
+
+			for store, want := range tt.stored {
+				if got := len(storedCopies(t, dir, store)); got != want {
+					t.Fatalf("copies stored by %s = %d, want %d", store, got, want)
+				}
+			}
+		})
+	}
+}
+
+// limitedProvider fails every upload after the first n.
+type limitedProvider struct {
+	provider.Provider
+	mu sync.Mutex
+	n  int
+}
+
+func (l *limitedProvider) Upload(ctx context.Context, payload []byte) (content.Meta, error) {
+	l.mu.Lock()
+	l.n--
+	exhausted := l.n < 0
+	l.mu.Unlock()
+
+	if exhausted {
+		return nil, local.ErrSimulatedFailure
+	}
+
+	return l.Provider.Upload(ctx, payload)
+}
+
+// interruptedUpload uploads data to alpha, failing after n copies, and
+// returns the configuration to run the upload again.
+func interruptedUpload(t *testing.T, dir string, data []byte, n int) func(*config.Upload) *config.Config {
+	t.Helper()
+
+	input := filepath.Join(dir, "input")
+	if err := os.WriteFile(input, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := func(upload *config.Upload) *config.Config {
+		upload.InputFilePath = input
+		upload.ChunkSize = 5000
+		upload.Copies = 1
+		return &config.Config{Providers: []string{"alpha"}, Upload: upload}
+	}
+
+	u := newTestUmbra(t, dir, cfg(&config.Upload{}))
+	u.providers[0] = &limitedProvider{Provider: u.providers[0], n: n}
+	if err := u.Upload(context.Background()); !errors.Is(err, local.ErrSimulatedFailure) {
+		t.Fatalf("Upload() error = %v, want ErrSimulatedFailure", err)
+	}
+
+	if got := len(storedCopies(t, dir, "alpha")); got != n {
+		t.Fatalf("copies stored = %d, want %d", got, n)
+	}
+
+	return cfg
+}
+
+func TestUploadResume(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(50000)
+	cfg := interruptedUpload(t, dir, data, 4)
+
+	u := newTestUmbra(t, dir, cfg(&config.Upload{}))
+	if err := u.Upload(context.Background()); !errors.Is(err, ErrJournalExists) {
+		t.Fatalf("Upload() error = %v, want ErrJournalExists", err)
+	}
+
+	resumed := cfg(&config.Upload{Resume: true})
+	u = newTestUmbra(t, dir, resumed)
+	if err := u.Upload(context.Background()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// only the missing chunks are uploaded
+	if got := len(storedCopies(t, dir, "alpha")); got != 10 {
+		t.Fatalf("copies stored = %d, want 10", got)
+	}
+
+	if _, err := os.Stat(u.journalPath()); !os.IsNotExist(err) {
+		t.Fatalf("journal kept after the upload, error = %v", err)
+	}
+
+	if got := downloadTestFile(t, dir, resumed.ManifestPath, &config.Config{}); !bytes.Equal(got, data) {
+		t.Fatal("downloaded data differs from the uploaded one")
+	}
+}
+
+func TestUploadResumeChangedInput(t *testing.T) {
+	t.Run("resized", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := interruptedUpload(t, dir, testData(50000), 4)
+
+		resumed := cfg(&config.Upload{Resume: true})
+		if err := os.WriteFile(resumed.Upload.InputFilePath, testData(40000), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		u := newTestUmbra(t, dir, resumed)
+		if err := u.Upload(context.Background()); !errors.Is(err, ErrJournalInputMismatch) {
+			t.Fatalf("Upload() error = %v, want ErrJournalInputMismatch", err)
+		}
+	})
+
+	t.Run("changed in place", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := interruptedUpload(t, dir, testData(50000), 4)
+
+		// the first chunk changes, the modification time is kept
+		resumed := cfg(&config.Upload{Resume: true})
+		info, err := os.Stat(resumed.Upload.InputFilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := testData(50000)
+		copy(data, "changed")
+		if err := os.WriteFile(resumed.Upload.InputFilePath, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(resumed.Upload.InputFilePath, info.ModTime(), info.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+
+		u := newTestUmbra(t, dir, resumed)
+		if err := u.Upload(context.Background()); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+
+		if got := downloadTestFile(t, dir, resumed.ManifestPath, &config.Config{}); !bytes.Equal(got, data) {
+			t.Fatal("downloaded data differs from the changed input")
+		}
+	})
+
+	t.Run("touched", func(t *testing.T) {
+		dir := t.TempDir()
+		data := testData(50000)
+		cfg := interruptedUpload(t, dir, data, 4)
+
+		resumed := cfg(&config.Upload{Resume: true})
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(resumed.Upload.InputFilePath, later, later); err != nil {
+			t.Fatal(err)
+		}
+
+		u := newTestUmbra(t, dir, resumed)
+		if err := u.Upload(context.Background()); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+
+		// the journaled chunks are still reused
+		if got := len(storedCopies(t, dir, "alpha")); got != 10 {
+			t.Fatalf("copies stored = %d, want 10", got)
+		}
+	})
+}
+
+func TestUploadAbandon(t *testing.T) {
+	dir := t.TempDir()
+	cfg := interruptedUpload(t, dir, testData(50000), 3)
+
+	u := newTestUmbra(t, dir, cfg(&config.Upload{Abandon: true}))
+	if err := u.Upload(context.Background()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// the orphaned copies are deleted along with the journal
+	if got := len(storedCopies(t, dir, "alpha")); got != 0 {
+		t.Fatalf("copies stored = %d, want 0", got)
+	}
+
+	if _, err := os.Stat(u.journalPath()); !os.IsNotExist(err) {
+		t.Fatalf("journal kept after abandoning, error = %v", err)
+	}
+}
+
+func TestUploadAutoChunks(t *testing.T) {
+	data := testData(150000)
+
+	for _, mode := range []string{compress.None, compress.Gzip, compress.Zstd, compress.Auto} {
+		t.Run(cmp.Or(mode, "raw"), func(t *testing.T) {
+			dir := t.TempDir()
+
+			// the smallest store sets the chunk size
+			manifestPath := uploadTestFile(t, dir, data, &config.Config{
+				Providers: []string{"alpha", "beta"},
+				ProviderOptions: map[string]map[string]string{
+					"alpha": {provider.OptionMaxSize: "65536"},
+					"beta":  {provider.OptionMaxSize: "20000"},
+				},
+				Upload: &config.Upload{Chunks: config.AutoChunks, Copies: 2, Compress: mode},
+			})
+
+			for _, store := range []string{"alpha", "beta"} {
+				sizes := storedCopies(t, dir, store)
+				if len(sizes) != 8 {
+					t.Fatalf("copies stored by %s = %d, want 8 even chunks", store, len(sizes))
+				}
+
+				for _, size := range sizes {
+					if size > 20000 {
+						t.Fatalf("copy stored by %s size = %d, want at most 20000", store, size)
+					}
+				}
+			}
+
+			if got := downloadTestFile(t, dir, manifestPath, &config.Config{}); !bytes.Equal(got, data) {
+				t.Fatal("downloaded data differs from the uploaded one")
+			}
+		})
+	}
+}
+
+func TestUploadAutoChunkSizeCap(t *testing.T) {
+	dir := t.TempDir()
+
+	input := filepath.Join(dir, "input")
+	if err := os.WriteFile(input, testData(1000), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  int64
+	}{
+		{"stream", config.Stdio, maxAutoChunkSize},
+		{"file", input, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newTestUmbra(t, dir, &config.Config{
+				Providers: []string{"alpha"},
+				ProviderOptions: map[string]map[string]string{
+					"alpha": {provider.OptionMaxSize: "1073741824"},
+				},
+				Upload: &config.Upload{InputFilePath: tt.input, Chunks: config.AutoChunks, Copies: 1},
+			})
+
+			chunkSize, _, err := u.calculateChunkSize()
+			if err != nil || chunkSize != tt.want {
+				t.Fatalf("calculateChunkSize() = %d, %v, want %d", chunkSize, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package umbra
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/henomis/umbra/internal/content"
+)
+
+// HealthStatus summarizes how recoverable a stored file is.
+type HealthStatus int
+
+const (
+	// Healthy means every verified copy is intact.
+	Healthy HealthStatus = iota
+	// Degraded means some copies are lost, but every chunk is still recoverable.
+	Degraded
+	// Unrecoverable means at least one chunk has no intact copy left.
+	Unrecoverable
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	default:
+		return "unrecoverable"
+	}
+}
+
+// CopyHealth is the outcome of verifying a single chunk copy.
+type CopyHealth struct {
+	Provider string
+	// Err is nil when the copy was downloaded, decrypted and matched the chunk hash.
+	Err error
+}
+
+// ChunkHealth holds the outcome of verifying every copy of a chunk.
+type ChunkHealth struct {
+	ID     uint32
+	Copies []CopyHealth
+}
+
+// Available returns the number of intact copies of the chunk.
+func (c *ChunkHealth) Available() int {
+	n := 0
+	for _, copyHealth := range c.Copies {
+		if copyHealth.Err == nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Status returns the health of the chunk.
+func (c *ChunkHealth) Status() HealthStatus {
+	switch c.Available() {
+	case 0:
+		return Unrecoverable
+	case len(c.Copies):
+		return Healthy
+	default:
+		return Degraded
+	}
+}
+
+// ProviderHealth counts the copies verified on a provider.
+type ProviderHealth struct {
+	Checked   int
+	Available int
+}
+
+// VerifyReport is the result of verifying the chunk copies of a stored file.
+type VerifyReport struct {
+	// Chunks holds the verified chunks. Zero chunks and chunks repeating
+	// another one are not stored and therefore not listed.
+	Chunks    []*ChunkHealth
+	Providers map[string]*ProviderHealth
+}
+
+// Status returns the health of the stored file, that is the worst health of
+// its chunks.
+func (r *VerifyReport) Status() HealthStatus {
+	status := Healthy
+	for _, chunk := range r.Chunks {
+		status = max(status, chunk.Status())
+	}
+
+	return status
+}
+
+// Redundancy returns the smallest number of intact copies left for a chunk.
+func (r *VerifyReport) Redundancy() int {
+	if len(r.Chunks) == 0 {
+		return 0
+	}
+
+	redundancy := r.Chunks[0].Available()
+	for _, chunk := range r.Chunks[1:] {
+		redundancy = min(redundancy, chunk.Available())
+	}
+
+	return redundancy
+}
+
+// AtRisk returns the chunks left with at most one intact copy.
+func (r *VerifyReport) AtRisk() []*ChunkHealth {
+	var chunks []*ChunkHealth
+	for _, chunk := range r.Chunks {
+		if chunk.Available() <= 1 {
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	return chunks
+}
+
+// Verify downloads every copy of the stored chunks, or of a random sample of
+// them, and checks it decrypts to the chunk hash without restoring the file.
+func (u *Umbra) Verify(ctx context.Context) (*VerifyReport, error) {
+	stored, crypto, err := u.loadContent(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// only chunks holding their own data are stored
+	var chunks []*content.Chunk
+	for i := range stored.Chunks {
+		if len(stored.Chunks[i].Copies) > 0 {
+			chunks = append(chunks, &stored.Chunks[i])
+		}
+	}
+
+	if sample := u.config.Verify.Sample; sample > 0 && sample < len(chunks) {
+		rand.Shuffle(len(chunks), func(i, j int) {
+			chunks[i], chunks[j] = chunks[j], chunks[i]
+		})
+		chunks = chunks[:sample]
+		slices.SortFunc(chunks, func(a, b *content.Chunk) int {
+			return int(a.ID) - int(b.ID)
+		})
+	}
+
+	report := &VerifyReport{
+		Chunks:    make([]*ChunkHealth, len(chunks)),
+		Providers: make(map[string]*ProviderHealth),
+	}
+
+	var total int64
+	for i, chunk := range chunks {
+		report.Chunks[i] = &ChunkHealth{
+			ID:     chunk.ID,
+			Copies: make([]CopyHealth, len(chunk.Copies)),
+		}
+		total += int64(len(chunk.Copies))
+	}
+
+	bar := u.newProgressBar("Verifying: ", total)
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, max(u.config.Verify.Parallel, 1))
+
+	for i, chunk := range chunks {
+		for j, c := range chunk.Copies {
+			select {
+			case workers <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+
+			wg.Go(func() {
+				defer func() { <-workers }()
+
+				_, err := u.fetchChunkCopy(ctx, chunk, c, crypto)
+				report.Chunks[i].Copies[j] = CopyHealth{Provider: c.Provider, Err: err}
+
+				if bar != nil {
+					bar.Increment()
+				}
+			})
+		}
+	}
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		if bar != nil {
+			bar.Abort(false)
+			bar.Wait()
+		}
+		return nil, err
+	}
+
+	if bar != nil {
+		bar.Wait()
+	}
+
+	for _, chunk := range report.Chunks {
+		for _, c := range chunk.Copies {
+			health, ok := report.Providers[c.Provider]
+			if !ok {
+				health = &ProviderHealth{}
+				report.Providers[c.Provider] = health
+			}
+
+			health.Checked++
+			if c.Err == nil {
+				health.Available++
+			}
+		}
+	}
+
+	u.printVerifyReport(report)
+
+	return report, nil
+}
+
+func (u *Umbra) printVerifyReport(report *VerifyReport) {
+	if u.config.Quiet {
+		return
+	}
+
+	w := tabwriter.NewWriter(u.output, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Chunks:\n")
+	for _, chunk := range report.Chunks {
+		fmt.Fprintf(w, "\tChunk %d:\t%d/%d copies\t%s\n", chunk.ID, chunk.Available(), len(chunk.Copies), chunk.Status())
+		for _, c := range chunk.Copies {
+			if c.Err != nil {
+				fmt.Fprintf(w, "\t\t%s:\t%v\n", c.Provider, c.Err)
+			}
+		}
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "Providers:\n")
+	for _, name := range slices.Sorted(maps.Keys(report.Providers)) {
+		health := report.Providers[name]
+		fmt.Fprintf(w, "\t%s:\t%d/%d copies\n", name, health.Available, health.Checked)
+	}
+	fmt.Fprintln(w)
+
+	atRisk := report.AtRisk()
+	fmt.Fprintf(w, "Redundancy:\t%d copies\n", report.Redundancy())
+	fmt.Fprintf(w, "At risk:\t%d chunks\n", len(atRisk))
+	for _, chunk := range atRisk {
+		fmt.Fprintf(w, "\tChunk %d:\t%d copies left\n", chunk.ID, chunk.Available())
+	}
+	fmt.Fprintf(w, "Status:\t%s\n", report.Status())
+
+	w.Flush()
+}
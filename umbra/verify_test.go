@@ -0,0 +1,67 @@
+package umbra
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/henomis/umbra/config"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+
+	manifestPath := uploadTestFile(t, dir, testData(50000), &config.Config{
+		Providers: []string{"alpha", "beta"},
+		Upload:    &config.Upload{ChunkSize: 10000, Copies: 2},
+	})
+
+	verify := func(sample int) *VerifyReport {
+		t.Helper()
+
+		u := newTestUmbra(t, dir, &config.Config{
+			ManifestPath: manifestPath,
+			Verify:       &config.Verify{Sample: sample, Parallel: 3},
+		})
+
+		report, err := u.Verify(context.Background())
+		if err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+
+		return report
+	}
+
+	if r := verify(0); r.Status() != Healthy || r.Redundancy() != 2 || len(r.Chunks) != 5 {
+		t.Fatalf("Verify() = %s, redundancy %d, %d chunks, want healthy, 2, 5", r.Status(), r.Redundancy(), len(r.Chunks))
+	}
+
+	if r := verify(2); len(r.Chunks) != 2 || r.Chunks[0].ID > r.Chunks[1].ID {
+		t.Fatalf("Verify() of a sample = %d chunks, want 2 in order", len(r.Chunks))
+	}
+
+	// a corrupted copy leaves its chunk at risk
+	path := storeFiles(t, dir, "alpha")[0]
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored[10] ^= 0xff
+	if err := os.WriteFile(path, stored, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := verify(0)
+	if r.Status() != Degraded || r.Redundancy() != 1 || len(r.AtRisk()) != 1 {
+		t.Fatalf("Verify() = %s, redundancy %d, %d at risk, want degraded, 1, 1", r.Status(), r.Redundancy(), len(r.AtRisk()))
+	}
+
+	if health := r.Providers["alpha"]; health.Checked != 5 || health.Available != 4 {
+		t.Fatalf("alpha health = %d/%d, want 4/5", health.Available, health.Checked)
+	}
+
+	clearStore(t, dir, "beta")
+	if r := verify(0); r.Status() != Unrecoverable {
+		t.Fatalf("Verify() = %s, want unrecoverable", r.Status())
+	}
+}